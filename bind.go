@@ -0,0 +1,337 @@
+package nile
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMemory is the number of bytes of a multipart body that are kept in memory
+// when it's parsed, with the rest of its files stored on disk.
+const maxMemory = 32 << 20
+
+// bindSource is a part of a request that struct fields can be bound from, and
+// the tag that names the value of a field in it.
+type bindSource struct {
+	tag string
+	// description describes a value from the source in error messages, such
+	// as "Query parameter".
+	description string
+	// invalid creates the error to set when a value can't be converted.
+	invalid func(name string, err error) *ErrorResponse
+}
+
+var (
+	pathSource   = bindSource{tag: "path", description: "Path parameter", invalid: NewInvalidParamError}
+	querySource  = bindSource{tag: "query", description: "Query parameter", invalid: NewInvalidQueryError}
+	headerSource = bindSource{tag: "header", description: "Header", invalid: NewInvalidInputError}
+	formSource   = bindSource{tag: "form", description: "Form field", invalid: NewInvalidInputError}
+)
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+	timeType        = reflect.TypeOf(time.Time{})
+	unmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (c *context) Bind(payload interface{}) error {
+	if c.err != nil && !c.accumulate {
+		return nil
+	}
+
+	target := reflect.ValueOf(payload)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		err := fmt.Errorf("Unable to bind request: payload must be a pointer to a struct, got %T", payload)
+		return c.setError(NewInternalServiceError(err))
+	}
+
+	recorded := len(c.errs)
+	if err := c.deferError(c.bindBody(payload, target.Elem())); err != nil {
+		return c.setError(err)
+	}
+
+	sources := []struct {
+		source bindSource
+		lookup func(name string) []string
+	}{
+		{pathSource, c.pathValues},
+		{querySource, c.queryValuesList},
+		{headerSource, c.request.Header.Values},
+	}
+
+	for _, s := range sources {
+		if err := c.bindFields(target.Elem(), s.source, s.lookup); err != nil {
+			return c.setError(err)
+		}
+	}
+
+	// A payload that couldn't be bound isn't validated, as the fields that
+	// failed would be reported again.
+	if len(c.errs) > recorded {
+		return NewAggregateError(c.errs[recorded:]...)
+	}
+
+	if err := validatePayload(payload); err != nil {
+		return c.setError(err)
+	}
+
+	return nil
+}
+
+// bindBody decodes the body of the request into a payload, choosing the
+// decoder by the Content-Type of the request. JSON bodies are decoded with the
+// json tags of the payload, and form bodies with its form tags. A request
+// without a body is left alone.
+func (c *context) bindBody(payload interface{}, target reflect.Value) *ErrorResponse {
+	req := c.request
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil && contentType != "" {
+		return NewUnsupportedMediaType(contentType)
+	}
+
+	switch {
+	case mediaType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		body := &countingReader{reader: req.Body}
+		if err := json.NewDecoder(body).Decode(payload); err != nil && !errors.Is(err, io.EOF) {
+			return newJSONError(err, body.count)
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		if err := req.ParseForm(); err != nil {
+			if tooLarge := newBodyTooLargeError(err); tooLarge != nil {
+				return tooLarge
+			}
+
+			return NewInvalidInputError("", err)
+		}
+
+		return c.bindFields(target, formSource, formValues(req.PostForm))
+	case mediaType == "multipart/form-data":
+		if err := req.ParseMultipartForm(maxMemory); err != nil {
+			if tooLarge := newBodyTooLargeError(err); tooLarge != nil {
+				return tooLarge
+			}
+
+			return NewInvalidInputError("", err)
+		}
+
+		if err := bindFiles(target, req.MultipartForm.File); err != nil {
+			return err
+		}
+
+		return c.bindFields(target, formSource, formValues(req.MultipartForm.Value))
+	default:
+		return NewUnsupportedMediaType(mediaType)
+	}
+
+	return nil
+}
+
+// pathValues looks up a path parameter for binding.
+func (c *context) pathValues(name string) []string {
+	if value, exists := c.TryParam(name); exists {
+		return []string{value}
+	}
+
+	return nil
+}
+
+// queryValuesList looks up a query string parameter for binding, in the same
+// way as QueryList.
+func (c *context) queryValuesList(name string) []string {
+	list, _ := c.queryList(name)
+	return list
+}
+
+// formValues creates a lookup function for the values of a parsed form.
+func formValues(form map[string][]string) func(name string) []string {
+	return func(name string) []string {
+		return form[name]
+	}
+}
+
+// bindFields sets the fields of a struct that have a tag for the source to
+// the values that lookup finds for them. Fields without values are left
+// alone, and the fields of embedded structs are bound as well. When the context
+// is accumulating errors, every field that can't be bound is reported.
+func (c *context) bindFields(target reflect.Value, source bindSource, lookup func(name string) []string) *ErrorResponse {
+	return eachField(target, source.tag, func(field reflect.Value, name string) *ErrorResponse {
+		if field.Type() == fileHeaderType || field.Type() == fileHeadersType {
+			return nil
+		}
+
+		values := lookup(name)
+		if len(values) == 0 {
+			return nil
+		}
+
+		kind, err := setField(field, values)
+		switch {
+		case err == nil:
+		case kind == "":
+			return c.deferError(NewInternalServiceError(err))
+		default:
+			return c.deferError(source.invalid(name, fmt.Errorf("%s %s must be %s", source.description, name, kind)))
+		}
+
+		return nil
+	})
+}
+
+// bindFiles sets the fields of a struct with a form tag that hold uploaded
+// files, which are either a *multipart.FileHeader or a slice of them.
+func bindFiles(target reflect.Value, files map[string][]*multipart.FileHeader) *ErrorResponse {
+	return eachField(target, formSource.tag, func(field reflect.Value, name string) *ErrorResponse {
+		uploaded := files[name]
+		if len(uploaded) == 0 {
+			return nil
+		}
+
+		switch field.Type() {
+		case fileHeaderType:
+			field.Set(reflect.ValueOf(uploaded[0]))
+		case fileHeadersType:
+			field.Set(reflect.ValueOf(uploaded))
+		}
+
+		return nil
+	})
+}
+
+// eachField calls fn for each settable field of a struct that has the tag,
+// with the name given in the tag. The fields of embedded structs are included.
+func eachField(target reflect.Value, tag string, fn func(field reflect.Value, name string) *ErrorResponse) *ErrorResponse {
+	structType := target.Type()
+	for idx := 0; idx < structType.NumField(); idx++ {
+		fieldType := structType.Field(idx)
+		field := target.Field(idx)
+
+		name, tagged := fieldType.Tag.Lookup(tag)
+		if name = strings.Split(name, ",")[0]; !tagged || name == "-" {
+			if fieldType.Anonymous && field.Kind() == reflect.Struct {
+				if err := eachField(field, tag, fn); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if !field.CanSet() {
+			continue
+		}
+
+		if name == "" {
+			name = fieldType.Name
+		}
+
+		if err := fn(field, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setField converts values to the type of a field and sets it. Slices are set
+// to every value, and other types to the first. If a value can't be converted,
+// it returns a description of the type that was expected, such as
+// "an integer". The description is empty if the field's type isn't supported.
+func setField(field reflect.Value, values []string) (string, error) {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for idx, value := range values {
+			if kind, err := setValue(slice.Index(idx), value); err != nil {
+				return kind, err
+			}
+		}
+
+		field.Set(slice)
+		return "", nil
+	}
+
+	return setValue(field, values[0])
+}
+
+// setValue converts a single value to the type of a field and sets it.
+func setValue(field reflect.Value, value string) (string, error) {
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if kind, err := setValue(elem.Elem(), value); err != nil {
+			return kind, err
+		}
+
+		field.Set(elem)
+		return "", nil
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(unmarshalerType) && field.Type() != timeType {
+		if err := field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return "valid", err
+		}
+
+		return "", nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return "a boolean", err
+		}
+
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return "an integer", err
+		}
+
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return "a positive integer", err
+		}
+
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return "a number", err
+		}
+
+		field.SetFloat(parsed)
+	case reflect.Struct:
+		if field.Type() != timeType {
+			return "", fmt.Errorf("Unable to bind a value to a %s", field.Type())
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return "an RFC 3339 time", err
+		}
+
+		field.Set(reflect.ValueOf(parsed))
+	default:
+		return "", fmt.Errorf("Unable to bind a value to a %s", field.Type())
+	}
+
+	return "", nil
+}
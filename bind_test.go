@@ -0,0 +1,331 @@
+package nile
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindPage struct {
+	Page  int       `query:"page"`
+	Sort  *string   `query:"sort"`
+	Tags  []string  `query:"tag"`
+	Since time.Time `query:"since"`
+}
+
+type bindProduct struct {
+	bindPage
+	ID       int64                 `path:"id" json:"-"`
+	Tenant   string                `header:"X-Tenant"`
+	Name     string                `json:"name" form:"name"`
+	Price    float64               `json:"price" form:"price"`
+	Active   bool                  `json:"active" form:"active"`
+	Image    *multipart.FileHeader `form:"image"`
+	hidden   string                `query:"hidden"`
+	validate func(p *bindProduct) *ErrorResponse
+}
+
+func (p *bindProduct) Validate() *ErrorResponse {
+	if p.validate != nil {
+		return p.validate(p)
+	}
+
+	return nil
+}
+
+func TestContextBind(t *testing.T) {
+	sort := "name"
+	since := time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	var multipartBody bytes.Buffer
+	writer := multipart.NewWriter(&multipartBody)
+	writer.WriteField("name", "Lamp")
+	writer.WriteField("price", "12.5")
+	image, _ := writer.CreateFormFile("image", "lamp.png")
+	image.Write([]byte("png"))
+	writer.Close()
+
+	var tests = []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		want        bindProduct
+		wantCode    string
+	}{
+		{
+			"json", "/products/7?page=2&sort=name&tag=a,b&tag=c&since=2021-06-01T00:00:00Z&hidden=x", "application/json",
+			`{"name":"Lamp","price":12.5,"active":true}`,
+			bindProduct{bindPage: bindPage{Page: 2, Sort: &sort, Tags: []string{"a", "b", "c"}, Since: since}, ID: 7, Tenant: "acme", Name: "Lamp", Price: 12.5, Active: true},
+			"",
+		},
+		{
+			"vendor json", "/products/7", "application/vnd.acme.v2+json; charset=utf-8", `{"name":"Lamp"}`,
+			bindProduct{ID: 7, Tenant: "acme", Name: "Lamp"}, "",
+		},
+		{"no body", "/products/7", "", "", bindProduct{ID: 7, Tenant: "acme"}, ""},
+		{
+			"form", "/products/7", "application/x-www-form-urlencoded", url.Values{"name": {"Lamp"}, "active": {"true"}}.Encode(),
+			bindProduct{ID: 7, Tenant: "acme", Name: "Lamp", Active: true}, "",
+		},
+		{"multipart", "/products/7", writer.FormDataContentType(), multipartBody.String(), bindProduct{ID: 7, Tenant: "acme", Name: "Lamp", Price: 12.5}, ""},
+		{"malformed json", "/products/7", "application/json", `{"name":`, bindProduct{}, "00004"},
+		{"invalid form field", "/products/7", "application/x-www-form-urlencoded", "price=cheap", bindProduct{}, "00008"},
+		{"invalid query", "/products/7?page=two", "", "", bindProduct{}, "00007"},
+		{"unsupported media type", "/products/7", "text/plain", "Lamp", bindProduct{}, "00009"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, test.target, strings.NewReader(test.body))
+		req.Header.Set("X-Tenant", "acme")
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+
+		c := &context{request: req, params: []param{{name: "id", value: "7"}}}
+		var got bindProduct
+		err := c.Bind(&got)
+
+		gotCode := ""
+		if c.err != nil {
+			gotCode = c.err.Code
+		}
+		if gotCode != test.wantCode {
+			t.Errorf("%s: Context.Bind() error code, want %q, got %q (%v)", test.name, test.wantCode, gotCode, err)
+		}
+		if test.wantCode != "" {
+			continue
+		}
+
+		if test.name == "multipart" {
+			if got.Image == nil || got.Image.Filename != "lamp.png" {
+				t.Errorf("%s: Context.Bind() Image, want lamp.png, got %+v", test.name, got.Image)
+			}
+			got.Image = nil
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Context.Bind(), want %+v, got %+v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestContextBindValidates(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/products/7", nil)
+	c := &context{request: req, params: []param{{name: "id", value: "seven"}}}
+
+	if err := c.Bind(&bindProduct{}); err == nil || c.err.Code != "00005" || c.err.Message != "Path parameter id must be an integer" {
+		t.Errorf("Context.Bind() with an invalid path parameter, want code 00005, got %v", c.err)
+	}
+
+	c = &context{request: req, params: []param{{name: "id", value: "7"}}}
+	payload := &bindProduct{validate: func(p *bindProduct) *ErrorResponse {
+		if p.Name == "" {
+			return NewBadRequest("10001", errors.New("Name is required"))
+		}
+		return nil
+	}}
+
+	if err := c.Bind(payload); err == nil || c.err.Code != "10001" {
+		t.Errorf("Context.Bind() with an invalid payload, want code 10001, got %v", c.err)
+	}
+
+	if err := c.Bind(&bindProduct{}); err != nil {
+		t.Errorf("Context.Bind() after an error, want <nil>, got %v", err)
+	}
+}
+
+func TestContextBindValidatesTags(t *testing.T) {
+	type createProduct struct {
+		Name  string `json:"name" validate:"required,max=8"`
+		Price int    `json:"price" validate:"min=1"`
+	}
+
+	r := New()
+	r.POST("/products", func(c Context) Response {
+		var payload createProduct
+		if err := c.BindJSON(&payload); err != nil {
+			return nil
+		}
+		return NewGenericResponse(http.StatusCreated, payload.Name)
+	})
+
+	var tests = []struct {
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{`{"name":"Lamp","price":10}`, http.StatusCreated, `"Lamp"`},
+		{
+			`{"name":"Lamp"}`, http.StatusBadRequest,
+			`{"code":"00010","details":[{"field":"price","code":"min","message":"price must be at least 1","value":0}],"message":"price must be at least 1","status":400}`,
+		},
+		{
+			`{"name":"","price":-1}`, http.StatusBadRequest,
+			`{"code":"00010","details":[{"field":"name","code":"required","message":"name is required","value":""},{"field":"price","code":"min","message":"price must be at least 1","value":-1}],"message":"name is required; price must be at least 1","status":400}`,
+		},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(test.body)))
+
+		if w.Code != test.wantStatus {
+			t.Errorf("POST /products with %s status, want %d, got %d", test.body, test.wantStatus, w.Code)
+		}
+		if w.Body.String() != test.wantBody {
+			t.Errorf("POST /products with %s body, want %s, got %s", test.body, test.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestContextBindRequiresPointer(t *testing.T) {
+	type createProduct struct {
+		Name string `json:"name"`
+	}
+
+	var tests = []struct {
+		name string
+		bind func(c Context) error
+	}{
+		{"BindJSON struct", func(c Context) error { return c.BindJSON(createProduct{}) }},
+		{"BindJSON nil pointer", func(c Context) error { return c.BindJSON((*createProduct)(nil)) }},
+		{"Bind struct", func(c Context) error { return c.Bind(createProduct{}) }},
+		{"Bind pointer to a map", func(c Context) error { return c.Bind(&map[string]string{}) }},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(`{"name":"Lamp"}`))
+		c := &context{request: req}
+
+		err := test.bind(c)
+		if err == nil || c.err == nil || c.err.StatusCode() != http.StatusInternalServerError {
+			t.Errorf("%s: error, want status %d, got %v", test.name, http.StatusInternalServerError, c.err)
+		}
+	}
+}
+
+func TestContextBindBodyTooLarge(t *testing.T) {
+	type createProduct struct {
+		Name string `json:"name" form:"name"`
+	}
+
+	name := strings.Repeat("a", 100)
+	multipartBody := &bytes.Buffer{}
+	form := multipart.NewWriter(multipartBody)
+	form.WriteField("name", name)
+	form.Close()
+
+	var tests = []struct {
+		name        string
+		bind        func(c Context, payload *createProduct) error
+		contentType string
+		body        string
+	}{
+		{"BindJSON", func(c Context, p *createProduct) error { return c.BindJSON(p) }, "application/json", `{"name":"` + name + `"}`},
+		{"Bind JSON", func(c Context, p *createProduct) error { return c.Bind(p) }, "application/json", `{"name":"` + name + `"}`},
+		{"Bind form", func(c Context, p *createProduct) error { return c.Bind(p) }, "application/x-www-form-urlencoded", "name=" + name},
+		{"Bind multipart form", func(c Context, p *createProduct) error { return c.Bind(p) }, form.FormDataContentType(), multipartBody.String()},
+	}
+
+	for _, test := range tests {
+		r := New()
+		r.POST("/products", func(c Context) Response {
+			var payload createProduct
+			if err := test.bind(c, &payload); err != nil {
+				return nil
+			}
+			return NewGenericResponse(http.StatusCreated, payload.Name)
+		}, MaxBodySize(10))
+
+		req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		want := `{"code":"00012","message":"Request body must not be larger than 10 bytes","status":413}`
+		if w.Code != http.StatusRequestEntityTooLarge || w.Body.String() != want {
+			t.Errorf("%s: POST /products status and body, want %d %s, got %d %s", test.name, http.StatusRequestEntityTooLarge, want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestContextErrorAccumulation(t *testing.T) {
+	type updateProduct struct {
+		Tenant string `header:"X-Tenant" json:"-"`
+		Limit  int    `header:"X-Limit" json:"-"`
+		Name   string `json:"name" validate:"required"`
+		Price  int    `json:"price"`
+	}
+
+	handler := func(c Context) Response {
+		c.ParamInt("id")
+		c.QueryInt("page")
+
+		var payload updateProduct
+		c.Bind(&payload)
+		if c.Error() != nil {
+			return nil
+		}
+		return NewGenericResponse(http.StatusOK, payload.Name)
+	}
+
+	var tests = []struct {
+		name       string
+		opts       []RouterOption
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"valid", []RouterOption{WithErrorAccumulation()}, "/products/7?page=1", `{"name":"Lamp"}`, http.StatusOK, `"Lamp"`},
+		{
+			"last error", nil, "/products/seven?page=one", `{"name":"Lamp"}`, http.StatusBadRequest,
+			`{"code":"00007","details":[{"field":"page","code":"00007","message":"Query parameter page must be an integer"}],"message":"Query parameter page must be an integer","status":400}`,
+		},
+		{
+			"accumulated", []RouterOption{WithErrorAccumulation()}, "/products/seven?page=one", `{"price":"cheap"}`, http.StatusBadRequest,
+			`{"code":"00011","details":[` +
+				`{"field":"id","code":"00005","message":"Parameter id must be an integer"},` +
+				`{"field":"page","code":"00007","message":"Query parameter page must be an integer"},` +
+				`{"field":"price","code":"type","message":"price must be an integer","offset":16},` +
+				`{"field":"X-Limit","code":"00008","message":"Header X-Limit must be an integer"}],` +
+				`"message":"Parameter id must be an integer; Query parameter page must be an integer; price must be an integer; Header X-Limit must be an integer","status":400}`,
+		},
+		{
+			"validated", []RouterOption{WithErrorAccumulation()}, "/products/seven?page=1", `{}`, http.StatusBadRequest,
+			`{"code":"00011","details":[` +
+				`{"field":"id","code":"00005","message":"Parameter id must be an integer"},` +
+				`{"field":"X-Limit","code":"00008","message":"Header X-Limit must be an integer"}],` +
+				`"message":"Parameter id must be an integer; Header X-Limit must be an integer","status":400}`,
+		},
+	}
+
+	for _, test := range tests {
+		r := New(test.opts...)
+		r.PUT("/products/:id", handler)
+
+		req := httptest.NewRequest(http.MethodPut, test.target, strings.NewReader(test.body))
+		if test.name != "valid" {
+			req.Header.Set("X-Limit", "many")
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != test.wantStatus {
+			t.Errorf("%s: PUT %s status, want %d, got %d", test.name, test.target, test.wantStatus, w.Code)
+		}
+		if w.Body.String() != test.wantBody {
+			t.Errorf("%s: PUT %s body, want %s, got %s", test.name, test.target, test.wantBody, w.Body.String())
+		}
+	}
+}
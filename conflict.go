@@ -0,0 +1,397 @@
+package nile
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ConflictKind identifies the kind of mistake that a RouteConflict describes.
+type ConflictKind string
+
+const (
+	// ConflictDuplicate is reported for routes with the same host pattern,
+	// HTTP method and request predicates whose paths only differ in the names
+	// of their parameters, such as /products/:id and /products/:sku. Only the
+	// first of them is ever matched.
+	ConflictDuplicate ConflictKind = "duplicate"
+
+	// ConflictAmbiguousParam is reported for parameters in the same position
+	// that have different names but the same constraint, when a route below
+	// one of them can't be reached. Requests are matched against the routes
+	// below the first of them before the others, so a route is unreachable
+	// when every request it matches is matched by one of those, such as
+	// DELETE /users/:userID after GET /users/:id. Routes such as
+	// /users/:userID/orders and /users/:id are fine.
+	ConflictAmbiguousParam ConflictKind = "ambiguous-param"
+
+	// ConflictUnreachable is reported for a route that no request is served
+	// by, such as a route whose request predicates include all those of an
+	// earlier route, or whose path isn't canonical when the Router's
+	// PathPolicy only serves canonical paths.
+	ConflictUnreachable ConflictKind = "unreachable"
+
+	// ConflictEmptyParam is reported for a parameter or wildcard without a
+	// name, such as /products/:.
+	ConflictEmptyParam ConflictKind = "empty-param"
+
+	// ConflictDuplicateParam is reported for a route that uses the same
+	// parameter name more than once, such as /a/:id/b/:id, including in its
+	// host pattern.
+	ConflictDuplicateParam ConflictKind = "duplicate-param"
+)
+
+// RouteConflict describes a mistake in the routes registered with a Router.
+type RouteConflict struct {
+	Kind ConflictKind
+	// Routes are the routes involved in the conflict, in the order that they
+	// are matched.
+	Routes []RouteInfo
+	// Message describes the conflict.
+	Message string
+}
+
+// Error returns the message of the RouteConflict, so that it can be returned
+// as an error when a Router is strict.
+func (c RouteConflict) Error() string {
+	return c.Message
+}
+
+// WithStrictRoutes makes registering a route fail when it would cause a
+// RouteConflict, rather than leaving it to be found with Router.Validate.
+func WithStrictRoutes() RouterOption {
+	return func(r *router) {
+		r.strict = true
+	}
+}
+
+func (r *router) Validate() []RouteConflict {
+	root := r.root()
+	return root.currentTable().validate(root.pathPolicy)
+}
+
+// validate finds the RouteConflicts in the routeTable, in the order that
+// routes are matched.
+func (t *routeTable) validate(policy PathPolicy) []RouteConflict {
+	v := &validator{policy: policy}
+	for _, routes := range t.hosts {
+		v.validateTree(routes.host.pattern, routes.host.params(), routes.tree)
+	}
+
+	v.validateTree("", nil, t.tree)
+	return v.conflicts
+}
+
+// validateRoute finds the RouteConflicts that involve the routes with a host
+// pattern and path template. A strict Router only checks the routes that a
+// change adds, since the rest of its routes have no conflicts, so that adding
+// a route doesn't check every other route.
+func (t *routeTable) validateRoute(policy PathPolicy, host string, path string) []RouteConflict {
+	v := &validator{policy: policy}
+	var hostParams []string
+	for _, routes := range t.hosts {
+		if routes.host.pattern == host {
+			hostParams = routes.host.params()
+		}
+	}
+
+	seg, err := t.segments(host, false)
+	if err != nil || seg == nil {
+		return nil
+	}
+
+	var template string
+	var params []string
+	for rest := path; ; {
+		head, tail := splitPath(rest)
+		child, exists := seg.child(head)
+		if !exists {
+			return v.conflicts
+		}
+
+		v.validateSiblings(host, seg, template, params, child)
+
+		template += "/" + head
+		if isParam(head) || isWildcard(head) {
+			params = append(params, child.ParamName())
+		}
+
+		seg, rest = child, tail
+		if rest == "" {
+			break
+		}
+	}
+
+	v.validateEndpoints(host, hostParams, seg, template, params)
+	return v.conflicts
+}
+
+// validator collects the RouteConflicts of a routeTable.
+type validator struct {
+	policy    PathPolicy
+	conflicts []RouteConflict
+}
+
+// validateTree checks the routes of a Segment tree for a host pattern.
+func (v *validator) validateTree(host string, hostParams []string, tree *segment) {
+	v.validateSiblings(host, tree, "", nil, nil)
+	for _, child := range tree.Children() {
+		child.walk("", nil, func(seg *segment, path string, params []string) error {
+			v.validateEndpoints(host, hostParams, seg, path, params)
+			v.validateSiblings(host, seg, path, params, nil)
+			return nil
+		})
+	}
+}
+
+// validateEndpoints checks the routes that end at a Segment.
+func (v *validator) validateEndpoints(host string, hostParams []string, seg *segment, path string, params []string) {
+	for _, endPt := range seg.Endpoints() {
+		route := newRouteInfo(endPt, host, path, params)
+		v.validateParams(route, hostParams)
+
+		if v.policy != PathIgnoreTrailingSlash && cleanPath(path) != path {
+			v.add(ConflictUnreachable, []RouteInfo{route}, "Route %s %s is unreachable: its path isn't canonical", route.Method, route.Path)
+		}
+	}
+
+	methods := make([]string, 0, len(seg.endpoints))
+	for method := range seg.endpoints {
+		methods = append(methods, method)
+	}
+
+	sort.Strings(methods)
+	for _, method := range methods {
+		endpoints := seg.endpoints[method]
+		for later, endPt := range endpoints {
+			for _, earlier := range endpoints[:later] {
+				if len(earlier.Predicates()) > 0 && includesPredicates(endPt.Predicates(), earlier.Predicates()) {
+					v.add(ConflictUnreachable,
+						[]RouteInfo{newRouteInfo(earlier, host, path, params), newRouteInfo(endPt, host, path, params)},
+						"Route %s %s with %s is unreachable: every request it accepts is served by the route with %s",
+						method, path, conditions(endPt.Predicates()), conditions(earlier.Predicates()))
+					break
+				}
+			}
+		}
+	}
+}
+
+// validateSiblings checks the routes below each pair of parameter children of
+// a Segment that have the same constraint. Only the pairs that include child
+// are checked, unless it's nil.
+func (v *validator) validateSiblings(host string, seg *segment, path string, params []string, child *segment) {
+	for idx, earlier := range seg.paramChildren {
+		for _, later := range seg.paramChildren[idx+1:] {
+			if child != nil && earlier != child && later != child {
+				continue
+			}
+
+			_, earlierConstraint := splitParam(earlier.Path)
+			_, laterConstraint := splitParam(later.Path)
+			if earlierConstraint == laterConstraint {
+				v.validateShadowed(host, path, params, earlier, later)
+			}
+		}
+	}
+}
+
+// validateShadowed checks whether routes below a parameter are shadowed by
+// routes below an earlier parameter, which has the same position and
+// constraint. Requests are matched against the routes of the earlier
+// parameter first, so a route is shadowed when every request it matches is
+// also matched by one of them, such as DELETE /users/:userID by GET
+// /users/:id. Shadowed routes with the same path, apart from the names of
+// their parameters, HTTP method and predicates as the route that shadows them
+// are reported as duplicates.
+func (v *validator) validateShadowed(host string, path string, params []string, earlier, later *segment) {
+	var earlierLeaves [][]*segment
+	leaves(earlier, nil, func(chain []*segment) {
+		earlierLeaves = append(earlierLeaves, chain)
+	})
+
+	var shadowing, shadowed []RouteInfo
+	seen := map[*segment]bool{}
+
+	leaves(later, nil, func(chain []*segment) {
+		for _, endPt := range chain[len(chain)-1].Endpoints() {
+			route := routeInfoAt(host, path, params, chain, endPt)
+			for _, earlierChain := range earlierLeaves {
+				if !coversPath(earlierChain, chain) {
+					continue
+				}
+
+				earlierLeaf := earlierChain[len(earlierChain)-1]
+				if duplicate := sameEndpoint(earlierLeaf, endPt); duplicate != nil && samePath(earlierChain, chain) {
+					first := routeInfoAt(host, path, params, earlierChain, duplicate)
+					v.add(ConflictDuplicate, []RouteInfo{first, route}, "Routes %s %s and %s %s are duplicates: only %s is matched",
+						first.Method, first.Path, route.Method, route.Path, first.Path)
+					break
+				}
+
+				if !seen[earlierLeaf] {
+					seen[earlierLeaf] = true
+					for _, earlierEndPt := range earlierLeaf.Endpoints() {
+						shadowing = append(shadowing, routeInfoAt(host, path, params, earlierChain, earlierEndPt))
+					}
+				}
+
+				shadowed = append(shadowed, route)
+				break
+			}
+		}
+	})
+
+	if len(shadowed) > 0 {
+		v.add(ConflictAmbiguousParam, append(shadowing, shadowed...),
+			"Parameters %s and %s of %s/ are ambiguous: requests for %s %s are matched against %s first",
+			earlier.Path, later.Path, path, shadowed[0].Method, shadowed[0].Path, earlier.Path)
+	}
+}
+
+// validateParams checks that the parameters of a route have unique names.
+func (v *validator) validateParams(route RouteInfo, hostParams []string) {
+	seen := map[string]bool{}
+	for _, name := range append(append([]string{}, hostParams...), route.Params...) {
+		switch {
+		case name == "":
+			v.add(ConflictEmptyParam, []RouteInfo{route}, "Route %s %s has a parameter without a name", route.Method, route.Path)
+		case seen[name]:
+			v.add(ConflictDuplicateParam, []RouteInfo{route}, "Route %s %s uses the parameter name %s more than once", route.Method, route.Path, name)
+		}
+
+		seen[name] = true
+	}
+}
+
+// add records a RouteConflict.
+func (v *validator) add(kind ConflictKind, routes []RouteInfo, format string, args ...interface{}) {
+	v.conflicts = append(v.conflicts, RouteConflict{
+		Kind:    kind,
+		Routes:  routes,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// leaves calls fn for a Segment and each of its descendants that has
+// Endpoints, in the order that they are matched, with the Segments leading to
+// it from seg.
+func leaves(seg *segment, chain []*segment, fn func(chain []*segment)) {
+	chain = append(chain[:len(chain):len(chain)], seg)
+	if len(seg.endpoints) > 0 {
+		fn(chain)
+	}
+
+	for _, child := range seg.Children() {
+		leaves(child, chain, fn)
+	}
+}
+
+// routeInfoAt describes the route of an Endpoint at the end of a chain of
+// Segments. The path template and parameter names leading up to the chain are
+// passed as path and params.
+func routeInfoAt(host string, path string, params []string, chain []*segment, endPt endpoint) RouteInfo {
+	params = params[:len(params):len(params)]
+	for _, seg := range chain {
+		path += "/" + seg.Path
+		if isParam(seg.Path) || isWildcard(seg.Path) {
+			params = append(params, seg.ParamName())
+		}
+	}
+
+	return newRouteInfo(endPt, host, path, params)
+}
+
+// coversPath checks whether every path matched by the chain of Segments later
+// is also matched by the chain earlier, which starts at the same position.
+func coversPath(earlier, later []*segment) bool {
+	for idx, seg := range earlier {
+		if isWildcard(seg.Path) {
+			return len(later) > idx
+		}
+
+		if idx >= len(later) || !coversSegment(seg, later[idx]) {
+			return false
+		}
+	}
+
+	return len(earlier) == len(later)
+}
+
+// coversSegment checks whether every value matched by the Segment later is
+// also matched by earlier.
+func coversSegment(earlier, later *segment) bool {
+	switch {
+	case isWildcard(later.Path):
+		return false
+	case !isParam(earlier.Path):
+		return earlier.Path == later.Path
+	case earlier.constraint == nil:
+		return true
+	case isParam(later.Path):
+		_, earlierConstraint := splitParam(earlier.Path)
+		_, laterConstraint := splitParam(later.Path)
+		return earlierConstraint == laterConstraint
+	default:
+		return earlier.constraint.MatchString(later.Path)
+	}
+}
+
+// samePath checks whether two chains of Segments only differ in the names of
+// their parameters.
+func samePath(first, second []*segment) bool {
+	if len(first) != len(second) {
+		return false
+	}
+
+	for idx := range first {
+		a, b := first[idx].Path, second[idx].Path
+		switch {
+		case isWildcard(a) || isWildcard(b):
+			if !isWildcard(a) || !isWildcard(b) {
+				return false
+			}
+		case isParam(a) || isParam(b):
+			_, aConstraint := splitParam(a)
+			_, bConstraint := splitParam(b)
+			if !isParam(a) || !isParam(b) || aConstraint != bConstraint {
+				return false
+			}
+		case a != b:
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameEndpoint finds the Endpoint of a Segment with the same HTTP method and
+// predicates as endPt, if there is one.
+func sameEndpoint(seg *segment, endPt endpoint) endpoint {
+	for _, existing := range seg.endpoints[endPt.Method()] {
+		if conditions(existing.Predicates()) == conditions(endPt.Predicates()) {
+			return existing
+		}
+	}
+
+	return nil
+}
+
+// includesPredicates checks whether every predicate in subset is also in
+// predicates.
+func includesPredicates(predicates []predicate, subset []predicate) bool {
+	for _, want := range subset {
+		found := false
+		for _, pred := range predicates {
+			if pred.description == want.description {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
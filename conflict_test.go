@@ -0,0 +1,124 @@
+package nile
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRouterValidate(t *testing.T) {
+	var tests = []struct {
+		name   string
+		policy PathPolicy
+		routes []string
+		opts   [][]RouteOption
+		want   []ConflictKind
+	}{
+		{"no conflicts", PathIgnoreTrailingSlash, []string{"/products", "/products/:id<int>", "/products/:slug", "/files/*path"}, nil, nil},
+		{"duplicate", PathIgnoreTrailingSlash, []string{"/products/:id", "/products/:sku"}, nil, []ConflictKind{ConflictDuplicate}},
+		{"duplicate with wildcard", PathIgnoreTrailingSlash, []string{"/files/:id/*path", "/files/:name/*rest"}, nil, []ConflictKind{ConflictDuplicate}},
+		{"distinct param routes", PathIgnoreTrailingSlash, []string{"/users/:userID/orders", "/users/:id"}, nil, nil},
+		{"distinct param subtrees", PathIgnoreTrailingSlash, []string{"/products/:id/reviews", "/products/:sku/stock"}, nil, nil},
+		{"distinct constrained param routes", PathIgnoreTrailingSlash, []string{"/products/:id<int>", "/products/:n<int>/stock"}, nil, nil},
+		{"ambiguous param", PathIgnoreTrailingSlash, []string{"/products/:id/:field", "/products/:sku/stock"}, nil, []ConflictKind{ConflictAmbiguousParam}},
+		{"ambiguous param with wildcard", PathIgnoreTrailingSlash, []string{"/products/:id/*rest", "/products/:sku/stock/:n"}, nil, []ConflictKind{ConflictAmbiguousParam}},
+		{"ambiguous constrained param", PathIgnoreTrailingSlash, []string{"/products/:id<int>/:field", "/products/:n<int>/stock"}, nil, []ConflictKind{ConflictAmbiguousParam}},
+		{"ambiguous root param", PathIgnoreTrailingSlash, []string{"/:tenant/:page", "/:locale/about"}, nil, []ConflictKind{ConflictAmbiguousParam}},
+		{"empty param", PathIgnoreTrailingSlash, []string{"/products/:"}, nil, []ConflictKind{ConflictEmptyParam}},
+		{"empty constrained param", PathIgnoreTrailingSlash, []string{"/products/:<int>"}, nil, []ConflictKind{ConflictEmptyParam}},
+		{"duplicate param", PathIgnoreTrailingSlash, []string{"/a/:id/b/:id"}, nil, []ConflictKind{ConflictDuplicateParam}},
+		{"duplicate wildcard name", PathIgnoreTrailingSlash, []string{"/a/:path/*path"}, nil, []ConflictKind{ConflictDuplicateParam}},
+		{"non-canonical path served as is", PathIgnoreTrailingSlash, []string{"/a/./b"}, nil, nil},
+		{"non-canonical path", PathRedirectCanonical, []string{"/a/./b"}, nil, []ConflictKind{ConflictUnreachable}},
+		{
+			"shadowed predicates", PathIgnoreTrailingSlash, []string{"/products", "/products"},
+			[][]RouteOption{{MatchHeader("X-Beta", "")}, {MatchHeader("X-Beta", ""), MatchQuery("v", "2")}},
+			[]ConflictKind{ConflictUnreachable},
+		},
+		{
+			"distinct predicates", PathIgnoreTrailingSlash, []string{"/products", "/products"},
+			[][]RouteOption{{MatchHeader("X-Beta", "")}, {MatchQuery("v", "2")}},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		r := New(WithPathPolicy(test.policy))
+		for idx, path := range test.routes {
+			var opts []RouteOption
+			if idx < len(test.opts) {
+				opts = test.opts[idx]
+			}
+			if err := r.GET(path, namedHandler(path), opts...); err != nil {
+				t.Fatalf("%s: Router.GET(%s) error, want <nil>, got %v", test.name, path, err)
+			}
+		}
+
+		got := r.Validate()
+		if len(got) != len(test.want) {
+			t.Errorf("%s: Router.Validate(), want %v, got %+v", test.name, test.want, got)
+			continue
+		}
+
+		for idx, conflict := range got {
+			if conflict.Kind != test.want[idx] {
+				t.Errorf("%s: Router.Validate()[%d].Kind, want %s, got %s", test.name, idx, test.want[idx], conflict.Kind)
+			}
+			if len(conflict.Routes) == 0 || conflict.Message == "" {
+				t.Errorf("%s: Router.Validate()[%d], want routes and a message, got %+v", test.name, idx, conflict)
+			}
+		}
+	}
+}
+
+func TestRouterValidateHosts(t *testing.T) {
+	r := New()
+	r.Host(":id.example.com").GET("/products/:id", namedHandler("tenant"))
+	r.Host("admin.example.com").GET("/products/:id", namedHandler("admin"))
+	r.GET("/products/:id", namedHandler("any"))
+
+	got := r.Validate()
+	if len(got) != 1 || got[0].Kind != ConflictDuplicateParam || got[0].Routes[0].Host != ":id.example.com" {
+		t.Errorf("Router.Validate(), want a duplicate-param conflict for :id.example.com, got %+v", got)
+	}
+}
+
+func TestRouterStrictRoutes(t *testing.T) {
+	r := New(WithStrictRoutes())
+	if err := r.GET("/products/:id", namedHandler("show")); err != nil {
+		t.Fatalf("Router.GET(/products/:id) error, want <nil>, got %v", err)
+	}
+
+	var tests = []struct {
+		method  string
+		path    string
+		wantErr bool
+	}{
+		{http.MethodGet, "/products/:sku", true},
+		{http.MethodDelete, "/products/:sku", true},
+		{http.MethodDelete, "/products/:id", false},
+		{http.MethodGet, "/orders/:", true},
+		{http.MethodGet, "/orders/:id/items/:id", true},
+		{http.MethodGet, "/orders/:id/items/:item", false},
+		{http.MethodGet, "/users/:userID/orders", false},
+		{http.MethodGet, "/users/:id", false},
+		{http.MethodDelete, "/users/:user", true},
+		{http.MethodGet, "/users/:user/orders", true},
+	}
+
+	for _, test := range tests {
+		err := r.Replace(test.method, test.path, namedHandler(test.path))
+		if (err != nil) != test.wantErr {
+			t.Errorf("Router.Replace(%s, %s) error, want error %v, got %v", test.method, test.path, test.wantErr, err)
+		}
+		if _, isConflict := err.(RouteConflict); err != nil && !isConflict {
+			t.Errorf("Router.Replace(%s, %s) error, want RouteConflict, got %T", test.method, test.path, err)
+		}
+	}
+
+	if got := len(r.Routes()); got != 5 {
+		t.Errorf("len(Router.Routes()), want 5, got %d", got)
+	}
+	if got := r.Validate(); len(got) != 0 {
+		t.Errorf("Router.Validate(), want no conflicts, got %+v", got)
+	}
+}
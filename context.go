@@ -2,8 +2,16 @@ package nile
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Context represents the information needed to interpret and interact with the
@@ -18,9 +26,28 @@ type Context interface {
 	// body into a Payload object. Failure to either unmarshal or validate will
 	// result the Context's internal error state being set and defaulting in an
 	// HTTP Bad Request Error. That error is returned here for convenience.
-	BindJSON(payload Payload) error
+	//
+	// A payload that doesn't implement Payload is validated with
+	// ValidateStruct instead of its Validate method. The payload must be a
+	// pointer, otherwise the error is an HTTP Internal Server Error, since the
+	// mistake is in the handler rather than the request.
+	BindJSON(payload interface{}) error
 
-	// Error returns any error that may be associated with the Context.
+	// Bind fills a Payload, which must be a pointer to a struct, from every
+	// part of an HTTP request and then validates it. Fields are bound from the
+	// path parameter, query string parameter or header named by their path,
+	// query or header tag. The body is decoded according to its Content-Type:
+	// JSON into the Payload as BindJSON does, and URL-encoded and multipart
+	// forms into the fields with a form tag. Path, query and header values
+	// take precedence over the body. Like BindJSON, the payload is then
+	// validated. Failure to bind or validate sets the Context's error state,
+	// which is returned here for convenience. Like BindJSON, a payload that
+	// isn't a pointer to a struct is an HTTP Internal Server Error.
+	Bind(payload interface{}) error
+
+	// Error returns any error that may be associated with the Context. When
+	// the Router was created with WithErrorAccumulation, it combines every
+	// error that was set on the Context.
 	Error() error
 
 	// Param gets the value of a URL parameter. If the value is not found, an
@@ -32,48 +59,359 @@ type Context interface {
 	// whether that parameter exists in the URL.
 	TryParam(name string) (string, bool)
 
+	// ParamInt gets the value of a URL parameter as an int. If the value can't
+	// be converted, an HTTP Bad Request Error is set on the context.
+	ParamInt(name string) int
+
+	// ParamInt64 gets the value of a URL parameter as an int64. If the value
+	// can't be converted, an HTTP Bad Request Error is set on the context.
+	ParamInt64(name string) int64
+
+	// ParamFloat gets the value of a URL parameter as a float64. If the value
+	// can't be converted, an HTTP Bad Request Error is set on the context.
+	ParamFloat(name string) float64
+
+	// ParamBool gets the value of a URL parameter as a bool. If the value can't
+	// be converted, an HTTP Bad Request Error is set on the context.
+	ParamBool(name string) bool
+
+	// ParamUUID gets the value of a URL parameter that must be a UUID, in its
+	// lowercase canonical form. If the value isn't a UUID, an HTTP Bad Request
+	// Error is set on the context.
+	ParamUUID(name string) string
+
+	// Query gets the first value of a query string parameter. If the parameter
+	// is missing, an HTTP Bad Request Error is set on the context.
+	Query(name string) string
+
+	// TryQuery gets the first value of a query string parameter and whether
+	// the parameter is in the query string.
+	TryQuery(name string) (string, bool)
+
+	// QueryDefault gets the first value of a query string parameter, or def
+	// if the parameter is missing.
+	QueryDefault(name string, def string) string
+
+	// QueryInt gets the value of a query string parameter as an int. If the
+	// parameter is missing or can't be converted, an HTTP Bad Request Error is
+	// set on the context.
+	QueryInt(name string) int
+
+	// QueryIntDefault gets the value of a query string parameter as an int, or
+	// def if the parameter is missing. If the value can't be converted, an
+	// HTTP Bad Request Error is set on the context.
+	QueryIntDefault(name string, def int) int
+
+	// QueryBool gets the value of a query string parameter as a bool. If the
+	// parameter is missing or can't be converted, an HTTP Bad Request Error is
+	// set on the context.
+	QueryBool(name string) bool
+
+	// QueryBoolDefault gets the value of a query string parameter as a bool,
+	// or def if the parameter is missing. If the value can't be converted, an
+	// HTTP Bad Request Error is set on the context.
+	QueryBoolDefault(name string, def bool) bool
+
+	// QueryTime gets the value of a query string parameter as a time in the
+	// RFC 3339 format. If the parameter is missing or can't be converted, an
+	// HTTP Bad Request Error is set on the context.
+	QueryTime(name string) time.Time
+
+	// QueryTimeDefault gets the value of a query string parameter as a time in
+	// the RFC 3339 format, or def if the parameter is missing. If the value
+	// can't be converted, an HTTP Bad Request Error is set on the context.
+	QueryTimeDefault(name string, def time.Time) time.Time
+
+	// QueryList gets every value of a query string parameter, splitting each
+	// one at commas, so that both ?tag=a&tag=b and ?tag=a,b give [a b]. If the
+	// parameter is missing, an HTTP Bad Request Error is set on the context.
+	QueryList(name string) []string
+
+	// QueryListDefault gets every value of a query string parameter in the
+	// same way as QueryList, or def if the parameter is missing.
+	QueryListDefault(name string, def []string) []string
+
 	// Request gets the reference to the original HTTP request made by the client.
 	Request() *http.Request
+
+	// Path gets the request path that was matched against the routes, after
+	// the Router's PathPolicy has been applied. It is decoded, except that a
+	// forward-slash or percent sign within a segment stays encoded as %2F or
+	// %25.
+	Path() string
+
+	// AllowedMethods gets the HTTP methods of the route that matched the
+	// request path, when the HandlerFunc set with WithMethodNotAllowed is
+	// responding. It's nil otherwise.
+	AllowedMethods() []string
+
+	// Route describes the route that matched the request, including the
+	// settings given with RouteOptions such as Scopes and Timeout. It must not
+	// be modified. Outside of a route's handler, such as when responding with
+	// the HandlerFunc set with WithNotFound, it's empty.
+	Route() RouteInfo
+
+	// URL generates the URL of a named route in the same way as Router.URL. If
+	// the URL can't be generated, an error is set on the context.
+	URL(name string, params map[string]string, query url.Values) (string, error)
 }
 
 type context struct {
 	err     *ErrorResponse
-	params  map[string]string
+	params  []param
 	request *http.Request
+	router  *router
+	path    string
+	allowed []string
+	route   *RouteInfo
+	// query caches the parsed query string of the request.
+	query url.Values
+	// errs holds every error that was set when the context is accumulating
+	// errors, with err combining them.
+	errs       []*ErrorResponse
+	accumulate bool
+	// writer is the http.ResponseWriter of the request, which a mounted
+	// http.Handler writes to directly. written is set once it has.
+	writer  http.ResponseWriter
+	written bool
 }
 
-func (c *context) BindJSON(payload Payload) error {
-	if c.err != nil {
+// param is the value of a single URL parameter. Parameters are stored in a
+// slice rather than a map so that a pooled context can reuse its storage.
+type param struct {
+	name  string
+	value string
+}
+
+// contextPool holds contexts that can be reused between requests.
+var contextPool = sync.Pool{
+	New: func() interface{} {
+		return &context{}
+	},
+}
+
+// acquireContext gets an empty context from the pool.
+func acquireContext() *context {
+	return contextPool.Get().(*context)
+}
+
+// releaseContext resets a context and returns it to the pool. The context must
+// not be used after it's released.
+func releaseContext(c *context) {
+	c.err = nil
+	c.errs = nil
+	c.accumulate = false
+	c.params = c.params[:0]
+	c.request = nil
+	c.router = nil
+	c.path = ""
+	c.allowed = nil
+	c.route = nil
+	c.query = nil
+	c.writer = nil
+	c.written = false
+	contextPool.Put(c)
+}
+
+func (c *context) BindJSON(payload interface{}) error {
+	if c.err != nil && !c.accumulate {
 		return nil
 	}
 
-	decoder := json.NewDecoder(c.request.Body)
-	if err := decoder.Decode(payload); err != nil {
-		return c.setError(NewJSONMalformedError(err))
+	if target := reflect.ValueOf(payload); target.Kind() != reflect.Ptr || target.IsNil() {
+		err := fmt.Errorf("Unable to bind request: payload must be a pointer, got %T", payload)
+		return c.setError(NewInternalServiceError(err))
+	}
+
+	body := &countingReader{reader: c.request.Body}
+	if err := json.NewDecoder(body).Decode(payload); err != nil {
+		return c.setError(newJSONError(err, body.count))
 	}
 
-	if err := payload.Validate(); err != nil {
+	if err := validatePayload(payload); err != nil {
 		return c.setError(err)
 	}
 
 	return nil
 }
 
+// countingReader counts the bytes read from a request body, so that an error
+// for a body that ends early can say where it ended.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// newJSONError creates the error for a JSON body that couldn't be decoded. When
+// a value has the wrong type, the details name the field. The details also
+// give the byte offset at which decoding stopped, with read being the number
+// of bytes of the body that were read.
+func newJSONError(err error, read int64) *ErrorResponse {
+	if tooLarge := newBodyTooLargeError(err); tooLarge != nil {
+		return tooLarge
+	}
+
+	resp := NewJSONMalformedError(err)
+
+	var detail FieldError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+
+		detail = FieldError{
+			Field:   field,
+			Code:    "type",
+			Message: fmt.Sprintf("%s must be %s", field, jsonType(typeErr.Type)),
+			Offset:  typeErr.Offset,
+		}
+	case errors.As(err, &syntaxErr):
+		detail = FieldError{
+			Code:    "syntax",
+			Message: fmt.Sprintf("Malformed JSON at byte %d: %s", syntaxErr.Offset, syntaxErr.Error()),
+			Offset:  syntaxErr.Offset,
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		detail = FieldError{
+			Code:    "syntax",
+			Message: fmt.Sprintf("Malformed JSON at byte %d: unexpected end of input", read),
+			Offset:  read,
+		}
+	default:
+		return resp
+	}
+
+	resp.Message = detail.Message
+	resp.Details = []FieldError{detail}
+	return resp
+}
+
+// newBodyTooLargeError creates the error for a request body that couldn't be
+// read because it's larger than the MaxBodySize of its route, or returns nil
+// for any other error.
+func newBodyTooLargeError(err error) *ErrorResponse {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return NewRequestTooLarge(maxErr.Limit)
+	}
+
+	return nil
+}
+
+// jsonType describes the JSON value that a Go type is decoded from.
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a positive integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return fmt.Sprintf("a %s", t)
+	}
+}
+
 func (c *context) Error() error {
+	if c.err == nil {
+		return nil
+	}
+
 	return c.err
 }
 
+// errorResponse gets the Response to use when a handler doesn't return one.
+// It is the Context's error, if there is one, and an internal error otherwise.
+func (c *context) errorResponse() Response {
+	if c.err != nil {
+		return c.err
+	}
+
+	return NewInternalServiceError(errors.New("Handler did not return a response"))
+}
+
 func (c *context) setError(er *ErrorResponse) error {
-	c.err = er
+	if !c.accumulate {
+		c.err = er
+		return er
+	}
+
+	for _, existing := range c.errs {
+		if existing.Code == er.Code && existing.Message == er.Message {
+			return er
+		}
+	}
+
+	c.errs = append(c.errs, er)
+	c.err = NewAggregateError(c.errs...)
 	return er
 }
 
+// deferError sets an error on the context and returns nil when the context is
+// accumulating errors, so that binding carries on. Otherwise, it returns the
+// error to stop binding.
+func (c *context) deferError(er *ErrorResponse) *ErrorResponse {
+	if er == nil || !c.accumulate {
+		return er
+	}
+
+	c.setError(er)
+	return nil
+}
+
 func (c context) Request() *http.Request {
 	return c.request
 }
 
+func (c *context) Path() string {
+	return c.path
+}
+
+func (c *context) AllowedMethods() []string {
+	return c.allowed
+}
+
+func (c *context) Route() RouteInfo {
+	if c.route == nil {
+		return RouteInfo{}
+	}
+
+	return *c.route
+}
+
+func (c *context) URL(name string, params map[string]string, query url.Values) (string, error) {
+	url, err := c.router.URL(name, params, query)
+	if err != nil {
+		return "", c.setError(NewInternalServiceError(err))
+	}
+
+	return url, nil
+}
+
 func (c *context) Param(name string) string {
-	param, exists := c.params[name]
+	param, exists := c.TryParam(name)
 	if !exists {
 		err := fmt.Errorf("Unable to parse parameter %s", name)
 		c.setError(NewInternalServiceError(err))
@@ -82,13 +420,256 @@ func (c *context) Param(name string) string {
 	return param
 }
 
-func (c context) TryParam(name string) (string, bool) {
-	param, exists := c.params[name]
+func (c *context) TryParam(name string) (string, bool) {
+	for idx := range c.params {
+		if c.params[idx].name == name {
+			return c.params[idx].value, true
+		}
+	}
+
+	return "", false
+}
+
+func (c *context) ParamInt(name string) int {
+	param, exists := c.typedParam(name)
+	if !exists {
+		return 0
+	}
+
+	value, err := strconv.Atoi(param)
+	if err != nil {
+		c.setError(NewInvalidParamError(name, fmt.Errorf("Parameter %s must be an integer", name)))
+		return 0
+	}
+
+	return value
+}
+
+func (c *context) ParamInt64(name string) int64 {
+	param, exists := c.typedParam(name)
+	if !exists {
+		return 0
+	}
+
+	value, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		c.setError(NewInvalidParamError(name, fmt.Errorf("Parameter %s must be an integer", name)))
+		return 0
+	}
+
+	return value
+}
+
+func (c *context) ParamFloat(name string) float64 {
+	param, exists := c.typedParam(name)
+	if !exists {
+		return 0
+	}
+
+	value, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		c.setError(NewInvalidParamError(name, fmt.Errorf("Parameter %s must be a number", name)))
+		return 0
+	}
+
+	return value
+}
+
+func (c *context) ParamBool(name string) bool {
+	param, exists := c.typedParam(name)
+	if !exists {
+		return false
+	}
+
+	value, err := strconv.ParseBool(param)
+	if err != nil {
+		c.setError(NewInvalidParamError(name, fmt.Errorf("Parameter %s must be a boolean", name)))
+		return false
+	}
+
+	return value
+}
+
+func (c *context) ParamUUID(name string) string {
+	param, exists := c.typedParam(name)
+	if !exists {
+		return ""
+	}
+
+	if !uuidPattern.MatchString(param) {
+		c.setError(NewInvalidParamError(name, fmt.Errorf("Parameter %s must be a UUID", name)))
+		return ""
+	}
+
+	return strings.ToLower(param)
+}
+
+// typedParam gets the value of a URL parameter that is about to be converted
+// to another type. Like Param, an error is set on the context if the parameter
+// doesn't exist.
+func (c *context) typedParam(name string) (string, bool) {
+	param, exists := c.TryParam(name)
+	if !exists {
+		c.Param(name)
+	}
+
 	return param, exists
 }
 
-func (c *context) addParam(name, value string) {
-	c.params[name] = value
+func (c *context) Query(name string) string {
+	value, exists := c.TryQuery(name)
+	if !exists {
+		c.setError(NewInvalidQueryError(name, fmt.Errorf("Query parameter %s is required", name)))
+		return ""
+	}
+
+	return value
+}
+
+func (c *context) TryQuery(name string) (string, bool) {
+	values := c.queryValues()[name]
+	if len(values) == 0 {
+		return "", false
+	}
+
+	return values[0], true
+}
+
+func (c *context) QueryDefault(name string, def string) string {
+	if value, exists := c.TryQuery(name); exists {
+		return value
+	}
+
+	return def
+}
+
+func (c *context) QueryInt(name string) int {
+	return c.queryInt(name, 0, true)
+}
+
+func (c *context) QueryIntDefault(name string, def int) int {
+	return c.queryInt(name, def, false)
+}
+
+func (c *context) queryInt(name string, def int, required bool) int {
+	param, exists := c.typedQuery(name, required)
+	if !exists {
+		return def
+	}
+
+	value, err := strconv.Atoi(param)
+	if err != nil {
+		c.setError(NewInvalidQueryError(name, fmt.Errorf("Query parameter %s must be an integer", name)))
+		return def
+	}
+
+	return value
+}
+
+func (c *context) QueryBool(name string) bool {
+	return c.queryBool(name, false, true)
+}
+
+func (c *context) QueryBoolDefault(name string, def bool) bool {
+	return c.queryBool(name, def, false)
+}
+
+func (c *context) queryBool(name string, def bool, required bool) bool {
+	param, exists := c.typedQuery(name, required)
+	if !exists {
+		return def
+	}
+
+	value, err := strconv.ParseBool(param)
+	if err != nil {
+		c.setError(NewInvalidQueryError(name, fmt.Errorf("Query parameter %s must be a boolean", name)))
+		return def
+	}
+
+	return value
+}
+
+func (c *context) QueryTime(name string) time.Time {
+	return c.queryTime(name, time.Time{}, true)
+}
+
+func (c *context) QueryTimeDefault(name string, def time.Time) time.Time {
+	return c.queryTime(name, def, false)
+}
+
+func (c *context) queryTime(name string, def time.Time, required bool) time.Time {
+	param, exists := c.typedQuery(name, required)
+	if !exists {
+		return def
+	}
+
+	value, err := time.Parse(time.RFC3339, param)
+	if err != nil {
+		c.setError(NewInvalidQueryError(name, fmt.Errorf("Query parameter %s must be an RFC 3339 time", name)))
+		return def
+	}
+
+	return value
+}
+
+func (c *context) QueryList(name string) []string {
+	list, exists := c.queryList(name)
+	if !exists {
+		c.setError(NewInvalidQueryError(name, fmt.Errorf("Query parameter %s is required", name)))
+	}
+
+	return list
+}
+
+func (c *context) QueryListDefault(name string, def []string) []string {
+	if list, exists := c.queryList(name); exists {
+		return list
+	}
+
+	return def
+}
+
+// queryList gets every value of a query string parameter, split at commas.
+// Empty values are skipped, and a parameter without any values is treated as
+// missing.
+func (c *context) queryList(name string) ([]string, bool) {
+	var list []string
+	for _, value := range c.queryValues()[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+
+	return list, len(list) > 0
+}
+
+// typedQuery gets the value of a query string parameter that is about to be
+// converted to another type. A parameter with an empty value, such as
+// ?page=, is treated as missing. If the parameter is required, an error is set
+// on the context when it's missing.
+func (c *context) typedQuery(name string, required bool) (string, bool) {
+	value, exists := c.TryQuery(name)
+	if exists && value != "" {
+		return value, true
+	}
+
+	if required {
+		c.setError(NewInvalidQueryError(name, fmt.Errorf("Query parameter %s is required", name)))
+	}
+
+	return "", false
+}
+
+// queryValues gets the parsed query string of the request. It's only parsed
+// the first time that it's needed.
+func (c *context) queryValues() url.Values {
+	if c.query == nil {
+		c.query = c.request.URL.Query()
+	}
+
+	return c.query
 }
 
 func (c *context) setRequest(req *http.Request) {
@@ -0,0 +1,212 @@
+package nile
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextTypedParams(t *testing.T) {
+	var tests = []struct {
+		value      string
+		convert    func(Context) interface{}
+		want       interface{}
+		wantStatus int
+	}{
+		{"42", func(c Context) interface{} { return c.ParamInt("p") }, 42, 0},
+		{"banana", func(c Context) interface{} { return c.ParamInt("p") }, 0, http.StatusBadRequest},
+		{"-9000000000", func(c Context) interface{} { return c.ParamInt64("p") }, int64(-9000000000), 0},
+		{"1.5", func(c Context) interface{} { return c.ParamFloat("p") }, 1.5, 0},
+		{"one", func(c Context) interface{} { return c.ParamFloat("p") }, 0.0, http.StatusBadRequest},
+		{"true", func(c Context) interface{} { return c.ParamBool("p") }, true, 0},
+		{"yes", func(c Context) interface{} { return c.ParamBool("p") }, false, http.StatusBadRequest},
+		{"6BA7B810-9DAD-11D1-80B4-00C04FD430C8", func(c Context) interface{} { return c.ParamUUID("p") }, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", 0},
+		{"6ba7b810", func(c Context) interface{} { return c.ParamUUID("p") }, "", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		c := &context{params: []param{{name: "p", value: test.value}}}
+
+		if got := test.convert(c); got != test.want {
+			t.Errorf("Typed param of %s, want %v, got %v", test.value, test.want, got)
+		}
+
+		gotStatus := 0
+		if c.err != nil {
+			gotStatus = c.err.StatusCode()
+		}
+		if gotStatus != test.wantStatus {
+			t.Errorf("Typed param of %s error status, want %d, got %d", test.value, test.wantStatus, gotStatus)
+		}
+	}
+}
+
+func TestContextTypedParamMissing(t *testing.T) {
+	c := &context{}
+	c.ParamInt("id")
+
+	if c.err == nil || c.err.StatusCode() != http.StatusInternalServerError {
+		t.Errorf("Context.ParamInt(id) error, want status %d, got %v", http.StatusInternalServerError, c.err)
+	}
+}
+
+func TestContextQuery(t *testing.T) {
+	since := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		query       string
+		convert     func(Context) interface{}
+		want        interface{}
+		wantMessage string
+	}{
+		{"q=shoes&q=boots", func(c Context) interface{} { return c.Query("q") }, "shoes", ""},
+		{"q=", func(c Context) interface{} { return c.Query("q") }, "", ""},
+		{"", func(c Context) interface{} { return c.Query("q") }, "", "Query parameter q is required"},
+		{"", func(c Context) interface{} { return c.QueryDefault("sort", "name") }, "name", ""},
+		{"page=2", func(c Context) interface{} { return c.QueryInt("page") }, 2, ""},
+		{"page=two", func(c Context) interface{} { return c.QueryInt("page") }, 0, "Query parameter page must be an integer"},
+		{"page=", func(c Context) interface{} { return c.QueryInt("page") }, 0, "Query parameter page is required"},
+		{"", func(c Context) interface{} { return c.QueryIntDefault("page", 1) }, 1, ""},
+		{"page=", func(c Context) interface{} { return c.QueryIntDefault("page", 1) }, 1, ""},
+		{"page=x", func(c Context) interface{} { return c.QueryIntDefault("page", 1) }, 1, "Query parameter page must be an integer"},
+		{"active=true", func(c Context) interface{} { return c.QueryBool("active") }, true, ""},
+		{"active=maybe", func(c Context) interface{} { return c.QueryBoolDefault("active", true) }, true, "Query parameter active must be a boolean"},
+		{"since=2021-06-01T12:00:00Z", func(c Context) interface{} { return c.QueryTime("since") }, since, ""},
+		{"since=yesterday", func(c Context) interface{} { return c.QueryTime("since") }, time.Time{}, "Query parameter since must be an RFC 3339 time"},
+		{"", func(c Context) interface{} { return c.QueryTimeDefault("since", since) }, since, ""},
+		{"tag=a,b&tag=c", func(c Context) interface{} { return strings.Join(c.QueryList("tag"), " ") }, "a b c", ""},
+		{"tag=", func(c Context) interface{} { return strings.Join(c.QueryList("tag"), " ") }, "", "Query parameter tag is required"},
+		{"", func(c Context) interface{} { return strings.Join(c.QueryListDefault("tag", []string{"new"}), " ") }, "new", ""},
+	}
+
+	for _, test := range tests {
+		c := &context{request: httptest.NewRequest(http.MethodGet, "/?"+test.query, nil)}
+
+		if got := test.convert(c); got != test.want {
+			t.Errorf("Query of %s, want %v, got %v", test.query, test.want, got)
+		}
+
+		gotMessage := ""
+		if c.err != nil {
+			gotMessage = c.err.Message
+			if c.err.StatusCode() != http.StatusBadRequest || c.err.Code != "00007" {
+				t.Errorf("Query of %s error, want status %d and code 00007, got %d and %s", test.query, http.StatusBadRequest, c.err.StatusCode(), c.err.Code)
+			}
+		}
+		if gotMessage != test.wantMessage {
+			t.Errorf("Query of %s error message, want %q, got %q", test.query, test.wantMessage, gotMessage)
+		}
+	}
+}
+
+type jsonProduct struct {
+	Name  string `json:"name"`
+	Price *int   `json:"price"`
+	Stock struct {
+		Count int `json:"count"`
+	} `json:"stock"`
+}
+
+func TestContextBindJSONErrors(t *testing.T) {
+	var tests = []struct {
+		body        string
+		wantMessage string
+		wantDetails []FieldError
+	}{
+		{`{"name":"Lamp","price":"cheap"}`, "price must be an integer", []FieldError{{Field: "price", Code: "type", Message: "price must be an integer", Offset: 30}}},
+		{`{"stock":{"count":true}}`, "stock.count must be an integer", []FieldError{{Field: "stock.count", Code: "type", Message: "stock.count must be an integer", Offset: 22}}},
+		{`["Lamp"]`, "body must be an object", []FieldError{{Field: "body", Code: "type", Message: "body must be an object", Offset: 1}}},
+		{`{"name":"Lamp",}`, "Malformed JSON at byte 16: invalid character '}' looking for beginning of object key string", []FieldError{{Code: "syntax", Message: "Malformed JSON at byte 16: invalid character '}' looking for beginning of object key string", Offset: 16}}},
+		{`{"name":`, "Malformed JSON at byte 8: unexpected end of input", []FieldError{{Code: "syntax", Message: "Malformed JSON at byte 8: unexpected end of input", Offset: 8}}},
+		{``, "EOF", nil},
+	}
+
+	for _, test := range tests {
+		c := &context{request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))}
+		c.BindJSON(&jsonProduct{})
+
+		if c.err == nil || c.err.Code != "00004" {
+			t.Errorf("Context.BindJSON(%s) error, want code 00004, got %v", test.body, c.err)
+			continue
+		}
+		if c.err.Message != test.wantMessage {
+			t.Errorf("Context.BindJSON(%s) message, want %q, got %q", test.body, test.wantMessage, c.err.Message)
+		}
+		if !reflect.DeepEqual(c.err.Details, test.wantDetails) {
+			t.Errorf("Context.BindJSON(%s) details, want %+v, got %+v", test.body, test.wantDetails, c.err.Details)
+		}
+	}
+}
+
+func TestNewValidationError(t *testing.T) {
+	err := NewValidationError(
+		FieldError{Field: "name", Code: "required", Message: "name is required"},
+		FieldError{Field: "price", Code: "min", Message: "price must be at least 1", Value: 0},
+	)
+
+	want := map[string]interface{}{
+		"status":  http.StatusBadRequest,
+		"code":    "00010",
+		"message": "name is required; price must be at least 1",
+		"details": err.Details,
+	}
+
+	if got := err.Body(); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewValidationError().Body(), want %v, got %v", want, got)
+	}
+	if got := NewBadRequest("10001", errors.New("Bad")).Body().(map[string]interface{}); got["details"] != nil {
+		t.Errorf("NewBadRequest().Body() details, want none, got %v", got["details"])
+	}
+}
+
+func TestNewAggregateError(t *testing.T) {
+	invalidPage := NewInvalidQueryError("page", errors.New("Query parameter page must be an integer"))
+	invalidSort := NewInvalidQueryError("sort", errors.New("Query parameter sort is required"))
+	invalidName := NewValidationError(FieldError{Field: "name", Code: "required", Message: "name is required"})
+	unsupported := NewUnsupportedMediaType("text/plain")
+	internal := NewInternalServiceError(errors.New("Database is down"))
+
+	var tests = []struct {
+		name        string
+		errs        []*ErrorResponse
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+		wantDetails []FieldError
+	}{
+		{"single", []*ErrorResponse{invalidName}, http.StatusBadRequest, "00010", "name is required", invalidName.Details},
+		{
+			"same code", []*ErrorResponse{invalidPage, invalidSort}, http.StatusBadRequest, "00007",
+			"Query parameter page must be an integer; Query parameter sort is required",
+			[]FieldError{{Field: "page", Code: "00007", Message: "Query parameter page must be an integer"}, {Field: "sort", Code: "00007", Message: "Query parameter sort is required"}},
+		},
+		{
+			"client errors", []*ErrorResponse{unsupported, invalidName}, http.StatusBadRequest, "00011",
+			"Unsupported media type text/plain; name is required",
+			[]FieldError{{Code: "00009", Message: "Unsupported media type text/plain"}, invalidName.Details[0]},
+		},
+		{
+			"server error", []*ErrorResponse{invalidPage, internal, unsupported}, http.StatusInternalServerError, "00011",
+			"Query parameter page must be an integer; An unknown error occurred; Unsupported media type text/plain",
+			[]FieldError{{Field: "page", Code: "00007", Message: "Query parameter page must be an integer"}, {Code: "00001", Message: "An unknown error occurred"}, {Code: "00009", Message: "Unsupported media type text/plain"}},
+		},
+	}
+
+	for _, test := range tests {
+		got := NewAggregateError(test.errs...)
+		if got.Status != test.wantStatus || got.Code != test.wantCode || got.Message != test.wantMessage {
+			t.Errorf("%s: NewAggregateError(), want %d %s %q, got %d %s %q", test.name, test.wantStatus, test.wantCode, test.wantMessage, got.Status, got.Code, got.Message)
+		}
+		if !reflect.DeepEqual(got.Details, test.wantDetails) {
+			t.Errorf("%s: NewAggregateError() details, want %+v, got %+v", test.name, test.wantDetails, got.Details)
+		}
+	}
+
+	if got := NewAggregateError(); got != nil {
+		t.Errorf("NewAggregateError() without errors, want <nil>, got %v", got)
+	}
+}
@@ -14,15 +14,34 @@ const (
 // endpoint that can be hit via an HTTP request. It contains an HTTP method
 // and handler for when the request matches.
 type endpoint interface {
-	// Handler is the method to call when the request the Endpoint.
+	// Handler is the method to call when the request the Endpoint. It is
+	// wrapped by any Middleware attached to the Endpoint each time it's called,
+	// so it should be called when routes are compiled rather than per request.
 	Handler() HandlerFunc
 
 	// Method gets the HTTP method to which Endpoint will respond.
 	Method() string
+
+	// Name gets the name given to the Endpoint's route, if it has one.
+	Name() string
+
+	// Settings gets the settings given to the Endpoint's route with
+	// RouteOptions such as Meta, Timeout and Deprecated.
+	Settings() routeSettings
+
+	// Predicates gets the conditions that a request must meet, in addition to
+	// its path and HTTP method, to be served by the Endpoint.
+	Predicates() []predicate
+
+	// Fallback reports whether the Endpoint serves requests that don't meet
+	// the Predicates of any other Endpoint with the same path and method.
+	Fallback() bool
 }
 
-// newEndpoint creates a new, valid Endpoint based on an HTTP method.
-func newEndpoint(method string, handler HandlerFunc) (endpoint, error) {
+// newEndpoint creates a new, valid Endpoint based on an HTTP method. Any
+// Middleware in the RouteOptions will only execute for requests to this
+// Endpoint.
+func newEndpoint(method string, handler HandlerFunc, opts ...RouteOption) (endpoint, error) {
 	// Validate that method is a currently supported HTTP method.
 	isSupported, ok := supportedMethods[method]
 	if !ok {
@@ -31,9 +50,14 @@ func newEndpoint(method string, handler HandlerFunc) (endpoint, error) {
 		return nil, fmt.Errorf(errUnsupportedMethod, method)
 	}
 
+	cfg := newRouteConfig(opts)
 	return &httpEndpoint{
-		method:  method,
-		handler: handler,
+		method:     method,
+		handler:    handler,
+		middleware: cfg.middleware,
+		settings:   cfg.routeSettings,
+		predicates: cfg.predicates,
+		fallback:   cfg.fallback || len(cfg.predicates) == 0,
 	}, nil
 }
 
@@ -41,8 +65,8 @@ var supportedMethods = map[string]bool{
 	http.MethodConnect: false,
 	http.MethodDelete:  true,
 	http.MethodGet:     true,
-	http.MethodHead:    false,
-	http.MethodOptions: false,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
 	http.MethodPatch:   true,
 	http.MethodPost:    true,
 	http.MethodPut:     true,
@@ -50,14 +74,34 @@ var supportedMethods = map[string]bool{
 }
 
 type httpEndpoint struct {
-	handler HandlerFunc
-	method  string
+	handler    HandlerFunc
+	method     string
+	middleware []Middleware
+	settings   routeSettings
+	predicates []predicate
+	fallback   bool
 }
 
 func (h *httpEndpoint) Handler() HandlerFunc {
-	return h.handler
+	return chain(h.handler, h.middleware)
 }
 
 func (h *httpEndpoint) Method() string {
 	return h.method
 }
+
+func (h *httpEndpoint) Name() string {
+	return h.settings.name
+}
+
+func (h *httpEndpoint) Settings() routeSettings {
+	return h.settings
+}
+
+func (h *httpEndpoint) Predicates() []predicate {
+	return h.predicates
+}
+
+func (h *httpEndpoint) Fallback() bool {
+	return h.fallback
+}
@@ -16,8 +16,8 @@ func TestEndpointMethods(t *testing.T) {
 		{http.MethodConnect, fmt.Errorf(errUnsupportedMethod, http.MethodConnect)},
 		{http.MethodDelete, nil},
 		{http.MethodGet, nil},
-		{http.MethodHead, fmt.Errorf(errUnsupportedMethod, http.MethodHead)},
-		{http.MethodOptions, fmt.Errorf(errUnsupportedMethod, http.MethodOptions)},
+		{http.MethodHead, nil},
+		{http.MethodOptions, nil},
 		{http.MethodPatch, nil},
 		{http.MethodPost, nil},
 		{http.MethodPut, nil},
@@ -1,6 +1,12 @@
 package nile
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jmataya/nile/validate"
+)
 
 // ErrorResponse is an opinionated structure for how errors should be
 // represented in an API. At their bare minimum, they should contain
@@ -18,8 +24,15 @@ type ErrorResponse struct {
 	Message         string
 	InternalMessage string
 	MoreInfo        string
+	// Details describes each of the fields of a request that caused the
+	// error, when there are any.
+	Details []FieldError
 }
 
+// FieldError describes a field of a request that caused an error, such as a
+// field of a Payload that broke a validation rule.
+type FieldError = validate.FieldError
+
 // Error writes the contents of the response as a string.
 func (er ErrorResponse) Error() string {
 	return er.InternalMessage
@@ -38,6 +51,10 @@ func (er ErrorResponse) Body() interface{} {
 		body["more_info"] = er.MoreInfo
 	}
 
+	if len(er.Details) > 0 {
+		body["details"] = er.Details
+	}
+
 	return body
 }
 
@@ -83,6 +100,20 @@ func NewMethodNotAllowed() *ErrorResponse {
 	}
 }
 
+// NewNotAcceptable returns an error that occurs when a route matches an HTTP
+// request path and method, but none of its endpoints accept the request's
+// headers or query, such as an unknown version in the Accept header.
+func NewNotAcceptable() *ErrorResponse {
+	const msg = "Not acceptable"
+
+	return &ErrorResponse{
+		Status:          http.StatusNotAcceptable,
+		Code:            "00006",
+		Message:         msg,
+		InternalMessage: msg,
+	}
+}
+
 // NewBadRequest returns an error when a 400 Bad Request should occur. The
 // general guidance is to use this error when a request is malformed for some
 // reason.
@@ -101,6 +132,139 @@ func NewJSONMalformedError(err error) *ErrorResponse {
 	return NewBadRequest("00004", err)
 }
 
+// NewValidationError returns an HTTP Bad Request Error for the fields of a
+// request that are invalid. Its message joins the messages of the fields, and
+// each field is described in the details of the body.
+func NewValidationError(fields ...FieldError) *ErrorResponse {
+	messages := make([]string, len(fields))
+	for idx, field := range fields {
+		messages[idx] = field.Message
+	}
+
+	msg := strings.Join(messages, "; ")
+
+	return &ErrorResponse{
+		Status:          http.StatusBadRequest,
+		Code:            "00010",
+		Message:         msg,
+		InternalMessage: msg,
+		Details:         fields,
+	}
+}
+
+// NewAggregateError returns an error that combines the errors found while
+// handling a request, so that they can all be reported at once. Its message
+// joins their messages, and its details list the details of each error, or
+// its code and message if it has none. It keeps the status and code that the
+// errors share. Otherwise, its code is 00011 and its status is the highest
+// server error status, or an HTTP Bad Request if they are all client errors. A
+// single error is returned as it is.
+func NewAggregateError(errs ...*ErrorResponse) *ErrorResponse {
+	if len(errs) <= 1 {
+		if len(errs) == 0 {
+			return nil
+		}
+		return errs[0]
+	}
+
+	status, code := errs[0].Status, errs[0].Code
+	messages := make([]string, len(errs))
+	internalMessages := make([]string, len(errs))
+	var details []FieldError
+
+	for idx, er := range errs {
+		messages[idx] = er.Message
+		internalMessages[idx] = er.InternalMessage
+
+		if er.Code != code {
+			code = "00011"
+		}
+
+		switch {
+		case er.Status >= http.StatusInternalServerError:
+			if status < er.Status {
+				status = er.Status
+			}
+		case status >= http.StatusInternalServerError:
+		case er.Status != status:
+			status = http.StatusBadRequest
+		}
+
+		if len(er.Details) > 0 {
+			details = append(details, er.Details...)
+		} else {
+			details = append(details, FieldError{Code: er.Code, Message: er.Message})
+		}
+	}
+
+	return &ErrorResponse{
+		Status:          status,
+		Code:            code,
+		Message:         strings.Join(messages, "; "),
+		InternalMessage: strings.Join(internalMessages, "; "),
+		Details:         details,
+	}
+}
+
+// NewInvalidParamError returns an error that occurs when a URL parameter can't
+// be converted to the type that a handler expects. The parameter is named in
+// the details of the body.
+func NewInvalidParamError(name string, err error) *ErrorResponse {
+	return newInvalidFieldError("00005", name, err)
+}
+
+// NewInvalidQueryError returns an error that occurs when a query string
+// parameter is missing or can't be converted to the type that a handler
+// expects. The parameter is named in the details of the body.
+func NewInvalidQueryError(name string, err error) *ErrorResponse {
+	return newInvalidFieldError("00007", name, err)
+}
+
+// NewInvalidInputError returns an error that occurs when a header or form field
+// can't be converted to the type that a handler expects, or a form can't be
+// parsed. The header or field is named in the details of the body, unless name
+// is empty because the error isn't about a single one.
+func NewInvalidInputError(name string, err error) *ErrorResponse {
+	return newInvalidFieldError("00008", name, err)
+}
+
+// newInvalidFieldError creates a Bad Request error with a code whose details
+// name the part of the request that was invalid.
+func newInvalidFieldError(code string, name string, err error) *ErrorResponse {
+	resp := NewBadRequest(code, err)
+	if name != "" {
+		resp.Details = []FieldError{{Field: name, Code: code, Message: resp.Message}}
+	}
+
+	return resp
+}
+
+// NewUnsupportedMediaType returns an error that occurs when the body of a
+// request has a Content-Type that can't be decoded.
+func NewUnsupportedMediaType(contentType string) *ErrorResponse {
+	msg := fmt.Sprintf("Unsupported media type %s", contentType)
+
+	return &ErrorResponse{
+		Status:          http.StatusUnsupportedMediaType,
+		Code:            "00009",
+		Message:         msg,
+		InternalMessage: msg,
+	}
+}
+
+// NewRequestTooLarge returns an error that occurs when the body of a request is
+// larger than the limit in bytes set for its route with MaxBodySize.
+func NewRequestTooLarge(limit int64) *ErrorResponse {
+	msg := fmt.Sprintf("Request body must not be larger than %d bytes", limit)
+
+	return &ErrorResponse{
+		Status:          http.StatusRequestEntityTooLarge,
+		Code:            "00012",
+		Message:         msg,
+		InternalMessage: msg,
+	}
+}
+
 // NewNotFoundError returns an error that is appropriate to use when an entity
 // is not found during the processing of a request and you want to signify the
 // result using a 404.
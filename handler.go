@@ -3,3 +3,18 @@ package nile
 // HandlerFunc is the method signature for accepting an HTTP request and
 // delivering a response.
 type HandlerFunc func(Context) Response
+
+// Middleware wraps a HandlerFunc with behavior that should run before or after
+// it. A Middleware may short-circuit the request by returning its own Response
+// (such as an *ErrorResponse) without calling the next HandlerFunc.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// chain wraps a HandlerFunc with a list of Middleware. The first Middleware in
+// the list is the outermost, so it is the first to execute.
+func chain(handler HandlerFunc, middleware []Middleware) HandlerFunc {
+	for idx := len(middleware) - 1; idx >= 0; idx-- {
+		handler = middleware[idx](handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,147 @@
+package nile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// hostPattern matches the Host of a request against a pattern such as
+// :tenant.api.example.com. Each label of the pattern is either matched exactly,
+// ignoring case, or is a parameter that captures the label of the Host in the
+// same position. Parameters may be constrained in the same way as in paths.
+type hostPattern struct {
+	pattern string
+	labels  []hostLabel
+}
+
+// hostLabel is a single dot-separated label of a hostPattern.
+type hostLabel struct {
+	value      string
+	paramName  string
+	constraint *regexp.Regexp
+}
+
+// newHostPattern parses a host pattern.
+func newHostPattern(pattern string) (*hostPattern, error) {
+	host := &hostPattern{pattern: pattern}
+	for _, value := range strings.Split(strings.TrimSuffix(pattern, "."), ".") {
+		if value == "" {
+			return nil, fmt.Errorf("Invalid host pattern %s: labels must not be empty", pattern)
+		}
+
+		label := hostLabel{value: value}
+		if isParam(value) {
+			name, constraint := splitParam(value)
+			if name == "" {
+				return nil, fmt.Errorf("Invalid host pattern %s: parameters must be named", pattern)
+			}
+
+			label.paramName = name
+			if constraint != "" {
+				re, err := compileConstraint(constraint)
+				if err != nil {
+					return nil, err
+				}
+
+				label.constraint = re
+			}
+		}
+
+		host.labels = append(host.labels, label)
+	}
+
+	return host, nil
+}
+
+// params gets the names of the parameters of the pattern.
+func (h *hostPattern) params() []string {
+	var names []string
+	for _, label := range h.labels {
+		if label.paramName != "" {
+			names = append(names, label.paramName)
+		}
+	}
+
+	return names
+}
+
+// before checks whether the pattern is more specific than another with the
+// same number of labels, so that it must be matched first. Labels are compared
+// from the left, and an exact label is more specific than a constrained
+// parameter, which is more specific than any other parameter. Patterns with a
+// different number of labels never match the same Host.
+func (h *hostPattern) before(other *hostPattern) bool {
+	if len(h.labels) != len(other.labels) {
+		return false
+	}
+
+	for idx, label := range h.labels {
+		if rank, otherRank := label.rank(), other.labels[idx].rank(); rank != otherRank {
+			return rank < otherRank
+		}
+	}
+
+	return false
+}
+
+// rank orders labels from the most to the least specific.
+func (l hostLabel) rank() int {
+	switch {
+	case l.paramName == "":
+		return 0
+	case l.constraint != nil:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// match checks the Host of a request against the pattern, appending the values
+// of any parameters to params. Any port in the Host is ignored.
+func (h *hostPattern) match(host string, params []param) ([]param, bool) {
+	rest := strings.TrimSuffix(stripPort(host), ".")
+	all := params
+
+	for idx, label := range h.labels {
+		last := idx == len(h.labels)-1
+		end := strings.IndexByte(rest, '.')
+		if last != (end < 0) {
+			return params, false
+		}
+
+		if end < 0 {
+			end = len(rest)
+		}
+
+		value := rest[:end]
+		switch {
+		case value == "":
+			return params, false
+		case label.paramName != "":
+			if label.constraint != nil && !label.constraint.MatchString(value) {
+				return params, false
+			}
+
+			all = append(all, param{name: label.paramName, value: value})
+		case !strings.EqualFold(value, label.value):
+			return params, false
+		}
+
+		if !last {
+			rest = rest[end+1:]
+		}
+	}
+
+	return all, true
+}
+
+// stripPort removes the port from a Host, if it has one.
+func stripPort(host string) string {
+	colon := strings.LastIndexByte(host, ':')
+	if colon < 0 || strings.IndexByte(host[colon:], ']') >= 0 {
+		return host
+	}
+
+	return host[:colon]
+}
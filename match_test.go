@@ -0,0 +1,111 @@
+package nile
+
+// match is a structure that contains the data for when a request matches an
+// endpoint using Segment.Matches. Segment.Matches walks the Segment tree
+// directly, and is kept as a reference that the compiled tree, which requests
+// are matched with, is checked against.
+type match struct {
+	Segment    *segment
+	Context    *context
+	RequestURI string
+}
+
+// newMatch creates a new Match object.
+func newMatch(seg *segment, path string) *match {
+	return &match{
+		Segment:    seg,
+		Context:    &context{},
+		RequestURI: path,
+	}
+}
+
+// AddParam adds a parameter value to the Match.
+func (m *match) AddParam(key, value string) {
+	m.Context.addParam(key, value)
+}
+
+// Param gets the value of a param, if it exists.
+func (m *match) Param(key string) (string, bool) {
+	return m.Context.TryParam(key)
+}
+
+// Matches checks a path against the current Segment's endpoints.
+// If a match doesn't exist, it checks against the Segment's children. A
+// Segment without endpoints never matches, so that a sibling is checked
+// instead.
+func (s *segment) Matches(path string) (*match, bool) {
+	if isWildcard(s.Path) {
+		match := newMatch(s, path)
+		match.AddParam(s.ParamName(), trimSlashes(path))
+		return match, true
+	}
+
+	head, tail := splitPath(path)
+
+	if isParam(s.Path) {
+		if s.constraint != nil && !s.constraint.MatchString(head) {
+			return nil, false
+		}
+	} else if head != s.Path {
+		return nil, false
+	}
+
+	if tail == "" {
+		if len(s.endpoints) == 0 {
+			return nil, false
+		}
+
+		match := newMatch(s, path)
+		if isParam(s.Path) {
+			match.AddParam(s.ParamName(), head)
+		}
+		return match, true
+	}
+
+	match, matches := s.matchChildren(tail)
+	if !matches {
+		return nil, false
+	}
+
+	match.RequestURI = path
+	if isParam(s.Path) {
+		match.AddParam(s.ParamName(), head)
+	}
+	return match, true
+}
+
+// matchChildren checks a path against the Segment's children, in order of
+// priority:
+//
+//  1. The static child with the same path.
+//  2. Constrained parameter children, in the order they were added.
+//  3. Unconstrained parameter children, in the order they were added.
+//  4. The wildcard child.
+//
+// If a child matches the start of the path but none of its descendants match
+// the rest, the next child in the order is checked.
+func (s *segment) matchChildren(path string) (*match, bool) {
+	head, _ := splitPath(path)
+
+	if child, exists := s.children[head]; exists {
+		if match, matches := child.Matches(path); matches {
+			return match, true
+		}
+	}
+
+	for _, child := range s.paramChildren {
+		if match, matches := child.Matches(path); matches {
+			return match, true
+		}
+	}
+
+	if s.wildcardChild != nil {
+		return s.wildcardChild.Matches(path)
+	}
+
+	return nil, false
+}
+
+func (c *context) addParam(name, value string) {
+	c.params = append(c.params, param{name: name, value: value})
+}
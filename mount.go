@@ -0,0 +1,161 @@
+package nile
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mountedMethods are the HTTP methods that are passed to a mounted
+// http.Handler.
+var mountedMethods = []string{
+	http.MethodDelete,
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodPatch,
+	http.MethodPost,
+	http.MethodPut,
+}
+
+func (r *router) Mount(prefix string, h http.Handler) error {
+	return r.mount(prefix, h, mountedMethods)
+}
+
+// mount registers an http.Handler for the path prefix and every path below
+// it, for each of the HTTP methods.
+func (r *router) mount(prefix string, h http.Handler, methods []string) error {
+	exact := joinPaths(prefix, "")
+	if exact == "" {
+		exact = "/"
+	}
+
+	exact, opts := r.scoped(exact, nil)
+	wildcard := joinPaths(exact, "*path")
+
+	// The mounted handler sees the path that follows the prefix, so the
+	// number of segments in the prefix is removed from the start of the
+	// request path.
+	depth := 0
+	if trimmed := trimSlashes(exact); trimmed != "" {
+		depth = strings.Count(trimmed, "/") + 1
+	}
+
+	handler := func(c Context) Response {
+		req := stripSegments(c.Request(), c.Path(), depth)
+
+		// The handler writes the response inside the Middleware, so that the
+		// Middleware sees its status code. If the Middleware replaced the
+		// Context, the handler writes the response once the Middleware returns
+		// instead.
+		if ctx, ok := c.(*context); ok && ctx.writer != nil && !ctx.written {
+			w := &statusWriter{ResponseWriter: ctx.writer}
+			h.ServeHTTP(w, req)
+			ctx.written = true
+			return handlerResponse{status: w.status}
+		}
+
+		return handlerResponse{handler: h, request: req}
+	}
+
+	return r.update(func(t *routeTable) error {
+		for _, method := range methods {
+			if err := t.add(r.host, exact, method, handler, opts); err != nil {
+				return err
+			}
+
+			if err := t.add(r.host, wildcard, method, handler, opts); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// handlerResponse is a Response that is written by an http.Handler rather
+// than encoded as JSON. When the handler has already written the response,
+// only the status code that it wrote is set. Otherwise, the handler and its
+// request are set, and the status code is unknown until it's written.
+type handlerResponse struct {
+	handler http.Handler
+	request *http.Request
+	status  int
+}
+
+func (h handlerResponse) Body() interface{} {
+	return nil
+}
+
+func (h handlerResponse) StatusCode() int {
+	if h.status == 0 {
+		return http.StatusOK
+	}
+
+	return h.status
+}
+
+// statusWriter is an http.ResponseWriter that records the status code of the
+// response written by a mounted http.Handler.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	// Informational responses, such as 103 Early Hints, precede the final
+	// status code.
+	if s.status == 0 && status >= http.StatusOK {
+		s.status = status
+	}
+
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap gets the underlying http.ResponseWriter, so that an
+// http.ResponseController can flush or hijack the connection.
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// stripSegments creates a copy of a request with the first depth segments of
+// its path removed, in the same way as http.StripPrefix. The segments are
+// removed from the path that was matched, given in the form described by
+// Context.Path, so that the mounted http.Handler sees the same path as the
+// Router after its PathPolicy is applied.
+func stripSegments(req *http.Request, path string, depth int) *http.Request {
+	stripped := new(http.Request)
+	*stripped = *req
+	stripped.URL = new(url.URL)
+	*stripped.URL = *req.URL
+	setPath(stripped.URL, stripPath(path, depth))
+
+	return stripped
+}
+
+// stripPath removes the first depth segments from a path. The remainder
+// always starts with a forward-slash.
+func stripPath(p string, depth int) string {
+	for ; depth > 0 && p != ""; depth-- {
+		p = strings.TrimPrefix(p, "/")
+		if end := strings.IndexByte(p, '/'); end >= 0 {
+			p = p[end:]
+		} else {
+			p = ""
+		}
+	}
+
+	if p == "" {
+		return "/"
+	}
+
+	return p
+}
@@ -0,0 +1,134 @@
+package nile
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterMount(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", req.Method, req.URL.Path, req.URL.RawPath)
+	})
+
+	sub := New()
+	sub.GET("/products/:id", namedHandler("product"))
+
+	var calls, status int
+	r := New()
+	r.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c Context) Response {
+			calls++
+			resp := next(c)
+			status = resp.StatusCode()
+			return resp
+		}
+	})
+
+	if err := r.Mount("/debug", echo); err != nil {
+		t.Fatalf("Router.Mount(/debug) error, want <nil>, got %v", err)
+	}
+	if err := r.Group("/tenants/:tenant").Mount("/legacy/", echo); err != nil {
+		t.Fatalf("Router.Group(/tenants/:tenant).Mount(/legacy/) error, want <nil>, got %v", err)
+	}
+	if err := r.Mount("/api", sub); err != nil {
+		t.Fatalf("Router.Mount(/api) error, want <nil>, got %v", err)
+	}
+	if err := r.Mount("/debug", echo); err == nil {
+		t.Error("Router.Mount(/debug) twice error, want error, got <nil>")
+	}
+
+	var tests = []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{http.MethodGet, "/debug", http.StatusOK, "GET / "},
+		{http.MethodGet, "/debug/", http.StatusOK, "GET / "},
+		{http.MethodPost, "/debug/pprof/profile", http.StatusOK, "POST /pprof/profile "},
+		{http.MethodGet, "/debug/pprof/", http.StatusOK, "GET /pprof/ "},
+		{http.MethodGet, "/debug/a%2Fb", http.StatusOK, "GET /a/b /a%2Fb"},
+		{http.MethodDelete, "/tenants/acme/legacy/orders/1", http.StatusOK, "DELETE /orders/1 "},
+		{http.MethodGet, "/api/products/1", http.StatusOK, `"product"`},
+		{http.MethodGet, "/api/orders/1", http.StatusNotFound, ""},
+		{http.MethodGet, "/debugger", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+		if w.Code != test.wantStatus {
+			t.Errorf("%s %s status, want %d, got %d", test.method, test.path, test.wantStatus, w.Code)
+			continue
+		}
+		if status != test.wantStatus {
+			t.Errorf("%s %s Middleware status, want %d, got %d", test.method, test.path, test.wantStatus, status)
+		}
+		if test.wantBody != "" && w.Body.String() != test.wantBody {
+			t.Errorf("%s %s body, want %q, got %q", test.method, test.path, test.wantBody, w.Body.String())
+		}
+	}
+
+	if calls != len(tests) {
+		t.Errorf("Middleware calls, want %d, got %d", len(tests), calls)
+	}
+}
+
+func TestRouterMountMiddlewareResponse(t *testing.T) {
+	teapot := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprint(w, "teapot")
+	})
+
+	r := New()
+	r.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c Context) Response {
+			next(c)
+			return NewGenericResponse(http.StatusInternalServerError, "replaced")
+		}
+	})
+	r.Mount("/teapot", teapot)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/teapot", nil))
+
+	if w.Code != http.StatusTeapot {
+		t.Errorf("GET /teapot status, want %d, got %d", http.StatusTeapot, w.Code)
+	}
+	if w.Body.String() != "teapot" {
+		t.Errorf("GET /teapot body, want %q, got %q", "teapot", w.Body.String())
+	}
+}
+
+func TestRouterMountCanonicalPath(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(w, "%s %s", req.URL.Path, req.URL.RawPath)
+	})
+
+	r := New(WithPathPolicy(PathServeCanonical))
+	if err := r.Mount("/api", echo); err != nil {
+		t.Fatalf("Router.Mount(/api) error, want <nil>, got %v", err)
+	}
+
+	var tests = []struct {
+		path     string
+		wantBody string
+	}{
+		{"/api//x/3", "/x/3 "},
+		{"/api/./x/../y/", "/y "},
+		{"//api/x%2Fy", "/x/y /x%2Fy"},
+		{"/api/caf%C3%A9", "/café "},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if w.Body.String() != test.wantBody {
+			t.Errorf("GET %s body, want %q, got %q", test.path, test.wantBody, w.Body.String())
+		}
+	}
+}
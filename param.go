@@ -0,0 +1,64 @@
+package nile
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// paramConstraints are the named constraints that may be used in a route
+// parameter, such as :id<int>. Any other constraint is treated as a regular
+// expression that must match the entire parameter value.
+var paramConstraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// uuidPattern matches a UUID in its canonical, hyphenated form.
+var uuidPattern = regexp.MustCompile("^" + paramConstraints["uuid"] + "$")
+
+// splitParam separates the segment path of a parameter or wildcard into the
+// parameter's name and its constraint. For example, :id<int> is split into id
+// and int. The constraint is empty when the parameter is unconstrained.
+func splitParam(path string) (name string, constraint string) {
+	name = path[1:]
+
+	start := strings.Index(name, "<")
+	if start < 0 || !strings.HasSuffix(name, ">") {
+		return
+	}
+
+	constraint = name[start+1 : len(name)-1]
+	name = name[:start]
+	return
+}
+
+// compileConstraint converts the constraint of a parameter into a regular
+// expression that matches the entire parameter value.
+func compileConstraint(constraint string) (*regexp.Regexp, error) {
+	pattern, found := paramConstraints[constraint]
+	if !found {
+		pattern = constraint
+	}
+
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid parameter constraint <%s>: %v", constraint, err)
+	}
+
+	return re, nil
+}
+
+// unescapeParams decodes the values of parameters that were matched against a
+// path with encoded forward-slashes or percent signs, as given by matchPath.
+func unescapeParams(params []param) {
+	for idx := range params {
+		if value, err := url.PathUnescape(params[idx].value); err == nil {
+			params[idx].value = value
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package nile
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// PathPolicy decides how a Router handles a request whose path isn't in its
+// canonical form. The canonical form of a path has a leading forward-slash, no
+// trailing or repeated forward-slashes, and no . or .. segments.
+type PathPolicy int
+
+const (
+	// PathIgnoreTrailingSlash matches paths as they are, except that a single
+	// trailing forward-slash is ignored. This is the default.
+	PathIgnoreTrailingSlash PathPolicy = iota
+
+	// PathServeCanonical serves a request with the route that matches its
+	// canonical path.
+	PathServeCanonical
+
+	// PathRedirectCanonical redirects a request to its canonical path. GET and
+	// HEAD requests are redirected with 301 Moved Permanently, and all other
+	// requests with 308 Permanent Redirect so that the method and body are
+	// kept.
+	PathRedirectCanonical
+
+	// PathRejectNonCanonical responds to a request with 404 Not Found unless
+	// its path is canonical.
+	PathRejectNonCanonical
+)
+
+// WithPathPolicy sets how a Router handles request paths that aren't in their
+// canonical form.
+func WithPathPolicy(policy PathPolicy) RouterOption {
+	return func(r *router) {
+		r.pathPolicy = policy
+	}
+}
+
+// cleanPath gets the canonical form of a path. It doesn't allocate when the
+// path is already canonical.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+
+	if p[0] != '/' {
+		p = "/" + p
+	}
+
+	return path.Clean(p)
+}
+
+// redirectCode gets the status code to use when redirecting a request with the
+// given HTTP method.
+func redirectCode(method string) int {
+	if method == http.MethodGet || method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+
+	return http.StatusPermanentRedirect
+}
+
+// matchPath gets the path of a request URL that is matched against routes. It
+// is decoded, except that a forward-slash or percent sign within a segment
+// stays encoded as %2F or %25. An encoded forward-slash therefore doesn't split
+// a segment in two, while static segments, such as /café, and parameter
+// constraints are compared with decoded text. It doesn't allocate unless the
+// path contains a percent sign.
+func matchPath(u *url.URL) string {
+	if u.RawPath == "" {
+		if strings.IndexByte(u.Path, '%') < 0 {
+			return u.Path
+		}
+
+		return strings.ReplaceAll(u.Path, "%", "%25")
+	}
+
+	escaped := u.EscapedPath()
+	var b strings.Builder
+	b.Grow(len(escaped))
+
+	for idx := 0; idx < len(escaped); idx++ {
+		if escaped[idx] == '%' && idx+2 < len(escaped) {
+			if c, ok := unhex(escaped[idx+1], escaped[idx+2]); ok && c != '/' && c != '%' {
+				b.WriteByte(c)
+				idx += 2
+				continue
+			}
+		}
+
+		b.WriteByte(escaped[idx])
+	}
+
+	return b.String()
+}
+
+// escapePath converts a path in the form given by matchPath back into an
+// escaped path, such as the RawPath of a URL.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for idx, segment := range segments {
+		if value, err := url.PathUnescape(segment); err == nil {
+			segments[idx] = url.PathEscape(value)
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// setPath sets the Path and RawPath of a URL to a path in the form given by
+// matchPath. Like url.URL, RawPath is only set when the path can't be encoded
+// from Path in the default way, such as when it has an encoded forward-slash.
+func setPath(u *url.URL, p string) {
+	escaped := escapePath(p)
+	u.Path, _ = url.PathUnescape(escaped)
+	u.RawPath = ""
+	if u.EscapedPath() != escaped {
+		u.RawPath = escaped
+	}
+}
+
+// unhex decodes the two hexadecimal digits of an escaped byte.
+func unhex(high, low byte) (byte, bool) {
+	value := 0
+	for _, c := range []byte{high, low} {
+		switch {
+		case '0' <= c && c <= '9':
+			value = value<<4 | int(c-'0')
+		case 'a' <= c && c <= 'f':
+			value = value<<4 | int(c-'a'+10)
+		case 'A' <= c && c <= 'F':
+			value = value<<4 | int(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+
+	return byte(value), true
+}
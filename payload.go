@@ -1,8 +1,39 @@
 package nile
 
+import "github.com/jmataya/nile/validate"
+
 // Payload is the structure that maps HTTP request payloads to structures and
 // defines the methodology for how their contents get validated.
 type Payload interface {
 	// Validate ensures that the Payload and its values are structured properly.
 	Validate() *ErrorResponse
 }
+
+// ValidateStruct validates a struct, or a pointer to one, against the validate
+// tags of its fields, as described in the validate package. If any field
+// breaks a rule, it returns an HTTP Bad Request Error with the details of each
+// field. It can be called from Payload.Validate, and is called automatically
+// when a payload bound by Context doesn't implement Payload.
+func ValidateStruct(v interface{}) *ErrorResponse {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	fieldErrs, ok := err.(validate.Errors)
+	if !ok {
+		return NewInternalServiceError(err)
+	}
+
+	return NewValidationError(fieldErrs...)
+}
+
+// validatePayload validates a payload that has been bound from a request, with
+// its Validate method if it's a Payload, and with ValidateStruct otherwise.
+func validatePayload(payload interface{}) *ErrorResponse {
+	if p, ok := payload.(Payload); ok {
+		return p.Validate()
+	}
+
+	return ValidateStruct(payload)
+}
@@ -0,0 +1,174 @@
+package nile
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// predicate is a condition on a request that selects between endpoints with
+// the same path and HTTP method, such as a version in the Accept header.
+type predicate struct {
+	// description identifies the predicate, so that two endpoints with the same
+	// conditions can be detected.
+	description string
+	// header is the name of the request header that the predicate checks, if
+	// it checks one, which responses must Vary by.
+	header  string
+	matches func(req *http.Request) bool
+}
+
+// MatchAccept restricts a route to requests that list a media type, such as
+// application/vnd.acme.v2+json, in their Accept header. Media type parameters
+// are ignored, and wildcards such as */* don't select the route. Responses for
+// the path and HTTP method of the route have a Vary: Accept header.
+func MatchAccept(mediaType string) RouteOption {
+	mediaType = strings.ToLower(mediaType)
+	return withPredicate(fmt.Sprintf("Accept: %s", mediaType), "Accept", func(req *http.Request) bool {
+		for _, header := range req.Header.Values("Accept") {
+			for _, accepted := range strings.Split(header, ",") {
+				accepted, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+				if err == nil && accepted == mediaType && params["q"] != "0" {
+					return true
+				}
+			}
+		}
+
+		return false
+	})
+}
+
+// MatchHeader restricts a route to requests with a header that has the given
+// value. If the value is empty, the header only needs to be present. Responses
+// for the path and HTTP method of the route Vary by the header.
+func MatchHeader(name string, value string) RouteOption {
+	name = http.CanonicalHeaderKey(name)
+	return withPredicate(fmt.Sprintf("%s: %s", name, value), name, func(req *http.Request) bool {
+		values, exists := req.Header[name]
+		if !exists {
+			return false
+		}
+
+		for _, actual := range values {
+			if value == "" || actual == value {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// MatchQuery restricts a route to requests with a query parameter that has
+// the given value. If the value is empty, the parameter only needs to be
+// present.
+func MatchQuery(name string, value string) RouteOption {
+	return withPredicate(fmt.Sprintf("?%s=%s", name, value), "", func(req *http.Request) bool {
+		values, exists := req.URL.Query()[name]
+		if !exists {
+			return false
+		}
+
+		for _, actual := range values {
+			if value == "" || actual == value {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// Fallback makes a route with request predicates, such as MatchAccept, serve
+// requests that don't match the predicates of any other route with the same
+// path and HTTP method. A route without predicates is always a fallback.
+func Fallback() RouteOption {
+	return routeOptionFunc(func(cfg *routeConfig) {
+		cfg.fallback = true
+	})
+}
+
+// withPredicate creates a RouteOption that adds a predicate to a route. The
+// header is the request header that the predicate checks, or empty if it
+// doesn't check one.
+func withPredicate(description string, header string, matches func(req *http.Request) bool) RouteOption {
+	return routeOptionFunc(func(cfg *routeConfig) {
+		cfg.predicates = append(cfg.predicates, predicate{
+			description: description,
+			header:      header,
+			matches:     matches,
+		})
+	})
+}
+
+// conditions describes a list of predicates. Endpoints with the same path and
+// HTTP method must have different conditions.
+func conditions(predicates []predicate) string {
+	descriptions := make([]string, len(predicates))
+	for idx, pred := range predicates {
+		descriptions[idx] = pred.description
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+// variants are the compiled endpoints of a route for a single HTTP method.
+type variants struct {
+	// predicated are the endpoints with predicates, in the order that they
+	// were registered.
+	predicated []*variant
+	// fallback serves requests that don't match any of the predicates. It may
+	// be nil.
+	fallback *variant
+	// vary are the request headers that the predicates check. Since they
+	// choose the endpoint, every response for the method Varies by them, so
+	// that a shared cache doesn't serve one endpoint's response for another.
+	vary []string
+}
+
+// addVary records the request headers that a list of predicates check.
+func (v *variants) addVary(predicates []predicate) {
+	for _, pred := range predicates {
+		if pred.header == "" {
+			continue
+		}
+
+		found := false
+		for _, header := range v.vary {
+			found = found || header == pred.header
+		}
+
+		if !found {
+			v.vary = append(v.vary, pred.header)
+		}
+	}
+}
+
+// variant is a compiled endpoint.
+type variant struct {
+	predicates []predicate
+	handler    HandlerFunc
+	route      RouteInfo
+}
+
+// endpoint selects the compiled endpoint for a request. The first endpoint
+// whose predicates all match is used, followed by the fallback. It returns nil
+// if no endpoint is acceptable.
+func (v *variants) endpoint(req *http.Request) *variant {
+	for _, candidate := range v.predicated {
+		matches := true
+		for _, pred := range candidate.predicates {
+			if !pred.matches(req) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return candidate
+		}
+	}
+
+	return v.fallback
+}
@@ -0,0 +1,165 @@
+package nile
+
+import "time"
+
+// RouteInfo describes a route that is registered with a Router.
+type RouteInfo struct {
+	// Method is the HTTP method of the route.
+	Method string
+	// Host is the host pattern of the route, or empty if it matches any host.
+	Host string
+	// Path is the path template of the route, such as /products/:id<int>.
+	Path string
+	// Params are the names of the parameters and wildcards in the path.
+	Params []string
+	// Name is the name of the route, if it has one.
+	Name string
+	// Description is the description given with Describe.
+	Description string
+	// Tags are the tags given with Tags, in the order that they were given.
+	Tags []string
+	// Scopes are the scopes given with Scopes that a client must be granted to
+	// use the route. Nile doesn't check them, so that Middleware can.
+	Scopes []string
+	// Timeout is the time limit given with Timeout, or zero if there isn't
+	// one.
+	Timeout time.Duration
+	// MaxBodySize is the limit in bytes given with MaxBodySize, or zero if
+	// there isn't one.
+	MaxBodySize int64
+	// Deprecated is the date given with Deprecated, or the zero time if the
+	// route isn't deprecated.
+	Deprecated time.Time
+	// Metadata holds the values given with Meta when the route was registered.
+	Metadata map[string]interface{}
+	// Conditions describes the request predicates, such as MatchAccept, that
+	// select the route from others with the same path and method.
+	Conditions string
+	// Fallback reports whether the route serves requests that don't meet the
+	// conditions of any other route with the same path and method.
+	Fallback bool
+}
+
+// WalkFunc is called for each route by Router.Walk. Returning an error stops
+// the walk, and the error is returned by Walk.
+type WalkFunc func(route RouteInfo) error
+
+// RouteOption configures a route when it's registered with a Router.
+// Middleware is a RouteOption, so it can be passed directly when registering a
+// route to execute only for that route.
+type RouteOption interface {
+	applyRoute(cfg *routeConfig)
+}
+
+// routeConfig is the configuration of a single route that is built from its
+// RouteOptions.
+type routeConfig struct {
+	routeSettings
+	middleware []Middleware
+	predicates []predicate
+	fallback   bool
+}
+
+// routeSettings are the parts of a routeConfig that describe a route, rather
+// than deciding which requests it serves.
+type routeSettings struct {
+	name        string
+	description string
+	tags        []string
+	scopes      []string
+	timeout     time.Duration
+	maxBodySize int64
+	deprecated  time.Time
+	metadata    map[string]interface{}
+}
+
+// newRouteConfig applies a list of RouteOptions to an empty configuration.
+func newRouteConfig(opts []RouteOption) *routeConfig {
+	cfg := &routeConfig{}
+	for _, opt := range opts {
+		opt.applyRoute(cfg)
+	}
+
+	return cfg
+}
+
+func (mw Middleware) applyRoute(cfg *routeConfig) {
+	cfg.middleware = append(cfg.middleware, mw)
+}
+
+// routeOptionFunc adapts a function to the RouteOption interface.
+type routeOptionFunc func(cfg *routeConfig)
+
+func (fn routeOptionFunc) applyRoute(cfg *routeConfig) {
+	fn(cfg)
+}
+
+// Name gives a route a name that is unique within a Router, so that its URL
+// can be generated with Router.URL.
+func Name(name string) RouteOption {
+	return routeOptionFunc(func(cfg *routeConfig) {
+		cfg.name = name
+	})
+}
+
+// Meta attaches an arbitrary value to a route, which can be read back with
+// Router.Routes.
+func Meta(key string, value interface{}) RouteOption {
+	return routeOptionFunc(func(cfg *routeConfig) {
+		if cfg.metadata == nil {
+			cfg.metadata = map[string]interface{}{}
+		}
+
+		cfg.metadata[key] = value
+	})
+}
+
+// Describe gives a route a human-readable description.
+func Describe(description string) RouteOption {
+	return routeOptionFunc(func(cfg *routeConfig) {
+		cfg.description = description
+	})
+}
+
+// Tags groups a route with others under one or more tags, such as the
+// resource that it acts on.
+func Tags(tags ...string) RouteOption {
+	return routeOptionFunc(func(cfg *routeConfig) {
+		cfg.tags = append(cfg.tags, tags...)
+	})
+}
+
+// Scopes lists the scopes that a client must be granted to use a route. They
+// are meant to be checked by authorization Middleware using Context.Route.
+func Scopes(scopes ...string) RouteOption {
+	return routeOptionFunc(func(cfg *routeConfig) {
+		cfg.scopes = append(cfg.scopes, scopes...)
+	})
+}
+
+// Timeout limits how long a route's handler may take. The context of the
+// request is cancelled once the time is up, so the handler must check it to
+// stop early.
+func Timeout(timeout time.Duration) RouteOption {
+	return routeOptionFunc(func(cfg *routeConfig) {
+		cfg.timeout = timeout
+	})
+}
+
+// MaxBodySize limits the size in bytes of a request body that a route's
+// handler may read. Reading beyond the limit fails with an error, for which
+// Context.Bind and Context.BindJSON set an HTTP Request Entity Too Large
+// Error.
+func MaxBodySize(size int64) RouteOption {
+	return routeOptionFunc(func(cfg *routeConfig) {
+		cfg.maxBodySize = size
+	})
+}
+
+// Deprecated marks a route as deprecated since a date. Responses from the
+// route include a Deprecation header with the date.
+func Deprecated(since time.Time) RouteOption {
+	return routeOptionFunc(func(cfg *routeConfig) {
+		cfg.deprecated = since
+	})
+}
@@ -1,55 +1,260 @@
 package nile
 
 import (
+	stdcontext "context"
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Router is the basic foundation of the HTTP server.
+//
+// Each of the route registration methods accepts an optional list of
+// RouteOptions, such as a Name or Middleware that only executes for that
+// route. Route Middleware runs after any Middleware added with Use.
+//
+// When more than one route matches a path, each segment of the path is matched
+// against the routes' segments in order of priority: a static segment, then
+// parameters with a constraint, then other parameters, and lastly a wildcard.
+// Parameters of the same kind are tried in the order they were registered. If
+// none of the routes below a segment match the rest of the path, the next
+// segment in this order is tried, so /a/b/c is matched by /a/:x/c even when
+// /a/b is a route.
+//
+// A Router is an http.Handler, so it can be served by any http.Server or
+// mounted in another Router.
 type Router interface {
+	http.Handler
+
 	// GET adds a GET request for the matching path that executes the corresponding
 	// HandlerFunc upon a match.
-	GET(path string, fn HandlerFunc) error
+	GET(path string, fn HandlerFunc, opts ...RouteOption) error
 
 	// POST adds a POST request for the matching path that executes the
 	// corresponding HandlerFunc upon a match.
-	POST(path string, fn HandlerFunc) error
+	POST(path string, fn HandlerFunc, opts ...RouteOption) error
 
 	// PATCH adds a PATCH request for the matching path that executes the
 	// corresponding HandlerFunc upon a match.
-	PATCH(path string, fn HandlerFunc) error
+	PATCH(path string, fn HandlerFunc, opts ...RouteOption) error
 
 	// PUT adds a PUT request for the matching path that executes the corresponding
 	// HandlerFunc upon a match.
-	PUT(path string, fn HandlerFunc) error
+	PUT(path string, fn HandlerFunc, opts ...RouteOption) error
 
 	// DELETE adds a DELETE request for the matching path that executes the
 	// corresponding HandlerFunc upon a match.
-	DELETE(path string, fn HandlerFunc) error
+	DELETE(path string, fn HandlerFunc, opts ...RouteOption) error
+
+	// HEAD adds a HEAD request for the matching path that executes the
+	// corresponding HandlerFunc upon a match. Without it, HEAD requests are
+	// served by the GET endpoint with the response body discarded.
+	HEAD(path string, fn HandlerFunc, opts ...RouteOption) error
+
+	// OPTIONS adds an OPTIONS request for the matching path that executes the
+	// corresponding HandlerFunc upon a match. Without it, OPTIONS requests are
+	// answered with an Allow header listing the path's HTTP methods.
+	OPTIONS(path string, fn HandlerFunc, opts ...RouteOption) error
+
+	// Use adds Middleware that executes for every route on the Router,
+	// including routes that were registered before Use was called. Middleware
+	// executes in the order that it was added. The Middleware of the root
+	// Router, but not of its groups, also executes for requests without a
+	// matching endpoint: the responses of WithNotFound and
+	// WithMethodNotAllowed, Not Acceptable responses, and the automatic
+	// response to OPTIONS requests, so that Middleware such as CORS can answer
+	// them.
+	Use(mw ...Middleware)
+
+	// Group creates a Router whose routes are registered with the path prefix
+	// prepended. The Middleware of a group only executes for routes in that
+	// group, after the Middleware of its parent Router. Groups may be nested.
+	Group(prefix string, mw ...Middleware) Router
+
+	// Host creates a Router whose routes only match requests with a Host that
+	// matches the pattern, such as :tenant.api.example.com. Parameters in the
+	// pattern can be read with Context.Param, like path parameters. Routes for
+	// a host are matched before routes for any host. Hosts are matched from the
+	// most to the least specific, comparing labels from the left, so that
+	// admin.example.com is matched before :tenant.example.com. Otherwise they
+	// are matched in the order that they were first used. Any port in the Host
+	// is ignored.
+	Host(pattern string) Router
+
+	// URL generates the URL of the route with the given name. Every parameter
+	// in the route's path must be given a value that satisfies its constraint.
+	// The query is optional. The URL of a route registered with Host starts
+	// with its host, such as //acme.example.com/products/1, so the parameters
+	// of its host pattern must be given values as well. Only routes for any
+	// host have URLs that are just a path.
+	URL(name string, params map[string]string, query url.Values) (string, error)
+
+	// Routes gets every route registered with the Router, sorted by path and
+	// then by HTTP method.
+	Routes() []RouteInfo
+
+	// Walk calls fn for every route registered with the Router, in the order
+	// that routes are matched.
+	Walk(fn WalkFunc) error
+
+	// Remove deletes the route with the given HTTP method and path template,
+	// such as /products/:id, including every route that differs only in its
+	// request predicates. It is safe to call while the Router is serving
+	// requests.
+	Remove(method string, path string) error
+
+	// Replace registers a route for any HTTP method, replacing the existing
+	// route with the same method, path template and request predicates if
+	// there is one. Requests are served by either the old or the new route,
+	// but never neither. It is safe to call while the Router is serving
+	// requests.
+	Replace(method string, path string, fn HandlerFunc, opts ...RouteOption) error
+
+	// Mount serves every request whose path starts with prefix with an
+	// http.Handler, such as a file server or another Router. The prefix is
+	// removed from the path of the request that the handler sees. The handler
+	// executes inside the Middleware of the Router, and the Response that the
+	// Middleware receives has the status code that the handler wrote. As the
+	// response has already been written, a different Response returned by the
+	// Middleware is ignored.
+	Mount(prefix string, h http.Handler) error
+
+	// Static serves the files of fsys, such as an embed.FS, under a path
+	// prefix for GET and HEAD requests. Conditional and Range requests are
+	// supported, and a gzipped copy of a file with a .gz extension is served
+	// to clients that accept it. Missing files respond with an ErrorResponse.
+	Static(prefix string, fsys fs.FS, opts ...StaticOption) error
+
+	// Validate finds mistakes in the routes registered with the Router, such
+	// as duplicate routes, ambiguous parameters and routes that can't be
+	// reached. A Router created with WithStrictRoutes refuses to register
+	// routes that cause a RouteConflict instead.
+	Validate() []RouteConflict
 
 	// Start initializes the router.
 	Start(addr string) error
 }
 
 type router struct {
-	segments map[string]*segment
+	// table holds the current *routeTable. It is replaced, rather than
+	// modified, whenever routes or Middleware change.
+	table atomic.Value
+	// mu ensures that changes to the routeTable are made one at a time.
+	mu         sync.Mutex
+	middleware []Middleware
+	pathPolicy PathPolicy
+
+	// draft is the routeTable that changes are made to until it replaces
+	// table, and changes are the functions that made it from table. drafted is
+	// set to 1, atomically, while there is a draft.
+	draft   *routeTable
+	changes []func(t *routeTable) error
+	drafted uint32
+
+	// notFound and methodNotAllowed respond to requests that don't match a
+	// route, or that match a route without an endpoint for their HTTP method.
+	notFound         HandlerFunc
+	methodNotAllowed HandlerFunc
+
+	// strict makes changes that cause a RouteConflict fail.
+	strict bool
+
+	// accumulate makes each Context collect its errors rather than keep the
+	// last one.
+	accumulate bool
+
+	// parent, prefix and host are only set when the router is a group. A group
+	// registers its routes in the table of the router at the top of the
+	// groups.
+	parent *router
+	prefix string
+	host   string
 }
 
+// RouterOption configures a Router when it's created with New.
+type RouterOption func(r *router)
+
 // New creates a new Router instance.
-func New() Router {
-	return &router{
-		segments: map[string]*segment{},
+func New(opts ...RouterOption) Router {
+	r := &router{
+		notFound:         notFound,
+		methodNotAllowed: methodNotAllowed,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.table.Store(newRouteTable())
+	return r
+}
+
+// WithNotFound sets the HandlerFunc that responds to requests that don't match
+// any route. Context.Path gets the path that was attempted. By default, the
+// response is an HTTP Not Found Error.
+func WithNotFound(fn HandlerFunc) RouterOption {
+	return func(r *router) {
+		r.notFound = fn
 	}
 }
 
+// WithMethodNotAllowed sets the HandlerFunc that responds to requests that
+// match a route, but not any of its HTTP methods. Context.AllowedMethods gets
+// the methods of the route, which are also set in the Allow header. By
+// default, the response is an HTTP Method Not Allowed Error.
+func WithMethodNotAllowed(fn HandlerFunc) RouterOption {
+	return func(r *router) {
+		r.methodNotAllowed = fn
+	}
+}
+
+// WithErrorAccumulation makes a Context collect every error that is set on it,
+// rather than keeping only the last one, so that a client can be told about
+// every problem with a request at once. Bind and BindJSON carry on binding
+// after an error, and the Context's error combines its errors as
+// NewAggregateError does.
+func WithErrorAccumulation() RouterOption {
+	return func(r *router) {
+		r.accumulate = true
+	}
+}
+
+// notFound is the default HandlerFunc for requests that don't match a route.
+func notFound(c Context) Response {
+	return NewResourceNotFound()
+}
+
+// methodNotAllowed is the default HandlerFunc for requests that match a route,
+// but not any of its HTTP methods.
+func methodNotAllowed(c Context) Response {
+	return NewMethodNotAllowed()
+}
+
+// notAcceptable is the HandlerFunc for requests that match a route and HTTP
+// method, but not the request predicates of any of its endpoints.
+func notAcceptable(c Context) Response {
+	return NewNotAcceptable()
+}
+
+// options responds to OPTIONS requests for a route that doesn't have an
+// OPTIONS endpoint. The methods of the route are listed in the Allow header.
+func options(c Context) Response {
+	return NewGenericResponse(http.StatusNoContent, nil)
+}
+
 func (r *router) Start(addr string) error {
 	server := &http.Server{
 		Addr:           addr,
-		Handler:        r,
+		Handler:        r.root(),
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
@@ -61,89 +266,399 @@ func (r *router) Start(addr string) error {
 }
 
 func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	path := req.URL.Path
+	root := r.root()
 	method := req.Method
 
-	var match *match
-	var hasMatch bool
-	for _, segment := range r.segments {
-		match, hasMatch = segment.Matches(path)
-		if hasMatch {
-			break
+	// Match against a path in which an encoded forward-slash doesn't split a
+	// parameter in two. Parameters are decoded once they are matched.
+	path := matchPath(req.URL)
+
+	context := acquireContext()
+	defer releaseContext(context)
+
+	context.setRequest(req)
+	context.router = root
+	context.accumulate = root.accumulate
+
+	table := root.compiledTable()
+	if root.pathPolicy != PathIgnoreTrailingSlash {
+		if canonical := cleanPath(path); canonical != path {
+			switch root.pathPolicy {
+			case PathRedirectCanonical:
+				target := *req.URL
+				setPath(&target, canonical)
+				w.Header().Set("Location", target.RequestURI())
+				w.WriteHeader(redirectCode(method))
+				return
+			case PathRejectNonCanonical:
+				context.path = path
+				r.serve(context, table.notFound, w)
+				return
+			default:
+				path = canonical
+			}
 		}
 	}
 
-	if !hasMatch {
-		r.writeResponse(NewResourceNotFound(), w)
+	context.path = path
+	route, params := table.lookup(req.Host, path, context.params)
+	if strings.IndexByte(path, '%') >= 0 {
+		unescapeParams(params)
+	}
+
+	context.params = params
+	if route == nil {
+		r.serve(context, table.notFound, w)
 		return
 	}
 
-	endpoint, found := match.Segment.Endpoint(method)
+	handlers, found := route.handlers[method]
+	if !found && method == http.MethodHead {
+		// Serve HEAD requests from the GET endpoint, keeping the headers but
+		// discarding the body.
+		handlers, found = route.handlers[http.MethodGet]
+		w = headResponseWriter{w}
+	}
+
 	if !found {
-		r.writeResponse(NewMethodNotAllowed(), w)
+		w.Header().Set("Allow", route.allow)
+		context.allowed = route.segment.AllowedMethods()
+		if method == http.MethodOptions {
+			r.serve(context, table.options, w)
+			return
+		}
+
+		r.serve(context, table.methodNotAllowed, w)
 		return
 	}
 
-	context := match.Context
-	context.setRequest(req)
-	handler := endpoint.Handler()
+	for _, header := range handlers.vary {
+		w.Header().Add("Vary", header)
+	}
 
+	compiled := handlers.endpoint(req)
+	if compiled == nil {
+		r.serve(context, table.notAcceptable, w)
+		return
+	}
+
+	info := &compiled.route
+	context.route = info
+	if info.Timeout > 0 || info.MaxBodySize > 0 {
+		var cancel stdcontext.CancelFunc
+		req, cancel = limitRequest(w, req, info)
+		defer cancel()
+		context.setRequest(req)
+	}
+
+	if !info.Deprecated.IsZero() {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(info.Deprecated.Unix(), 10))
+	}
+
+	r.serve(context, compiled.handler, w)
+}
+
+// limitRequest applies the Timeout and MaxBodySize of a route to a copy of a
+// request. The returned function releases the resources of the timeout.
+func limitRequest(w http.ResponseWriter, req *http.Request, route *RouteInfo) (*http.Request, stdcontext.CancelFunc) {
+	ctx, cancel := req.Context(), stdcontext.CancelFunc(func() {})
+	if route.Timeout > 0 {
+		ctx, cancel = stdcontext.WithTimeout(ctx, route.Timeout)
+	}
+
+	limited := req.WithContext(ctx)
+	if route.MaxBodySize > 0 && req.Body != nil {
+		limited.Body = http.MaxBytesReader(w, req.Body, route.MaxBodySize)
+	}
+
+	return limited, cancel
+}
+
+// serve writes the Response of a HandlerFunc. If the handler doesn't return a
+// Response, the error of the Context is written instead.
+func (r *router) serve(context *context, handler HandlerFunc, w http.ResponseWriter) {
+	context.writer = w
 	resp := handler(context)
+	if context.written {
+		return
+	}
+
+	if resp == nil {
+		resp = context.errorResponse()
+	}
+
 	r.writeResponse(resp, w)
 }
 
 func (r *router) writeResponse(resp Response, w http.ResponseWriter) {
+	if mounted, ok := resp.(handlerResponse); ok {
+		mounted.handler.ServeHTTP(w, mounted.request)
+		return
+	}
+
+	// A No Content response must not have a body, not even an empty JSON
+	// value.
+	if resp.StatusCode() == http.StatusNoContent {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	respBytes, err := json.Marshal(resp.Body())
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(respBytes)))
 	w.WriteHeader(resp.StatusCode())
 	w.Write(respBytes)
 }
 
-func (r *router) GET(path string, fn HandlerFunc) error {
-	return r.addRoute(path, http.MethodGet, fn)
+// headResponseWriter is an http.ResponseWriter that discards the body of a
+// response so that a GET handler can be used to respond to a HEAD request.
+type headResponseWriter struct {
+	http.ResponseWriter
 }
 
-func (r *router) POST(path string, fn HandlerFunc) error {
-	return r.addRoute(path, http.MethodPost, fn)
+func (h headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
 }
 
-func (r *router) PATCH(path string, fn HandlerFunc) error {
-	return r.addRoute(path, http.MethodPatch, fn)
+func (r *router) GET(path string, fn HandlerFunc, opts ...RouteOption) error {
+	return r.addRoute(path, http.MethodGet, fn, opts)
 }
 
-func (r *router) PUT(path string, fn HandlerFunc) error {
-	return r.addRoute(path, http.MethodPut, fn)
+func (r *router) POST(path string, fn HandlerFunc, opts ...RouteOption) error {
+	return r.addRoute(path, http.MethodPost, fn, opts)
 }
 
-func (r *router) DELETE(path string, fn HandlerFunc) error {
-	return r.addRoute(path, http.MethodDelete, fn)
+func (r *router) PATCH(path string, fn HandlerFunc, opts ...RouteOption) error {
+	return r.addRoute(path, http.MethodPatch, fn, opts)
 }
 
-func (r *router) addRoute(path string, method string, handler HandlerFunc) error {
-	seg, err := newSegmentEndpoint(path, method, handler)
-	if err != nil {
-		return err
+func (r *router) PUT(path string, fn HandlerFunc, opts ...RouteOption) error {
+	return r.addRoute(path, http.MethodPut, fn, opts)
+}
+
+func (r *router) DELETE(path string, fn HandlerFunc, opts ...RouteOption) error {
+	return r.addRoute(path, http.MethodDelete, fn, opts)
+}
+
+func (r *router) HEAD(path string, fn HandlerFunc, opts ...RouteOption) error {
+	return r.addRoute(path, http.MethodHead, fn, opts)
+}
+
+func (r *router) OPTIONS(path string, fn HandlerFunc, opts ...RouteOption) error {
+	return r.addRoute(path, http.MethodOptions, fn, opts)
+}
+
+func (r *router) Use(mw ...Middleware) {
+	root := r.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	// The Middleware is only wrapped around the handlers when the routes are
+	// compiled, so a draft is created to have them compiled again.
+	r.middleware = append(r.middleware, mw...)
+	root.edit()
+}
+
+func (r *router) Group(prefix string, mw ...Middleware) Router {
+	return &router{
+		middleware: mw,
+		parent:     r,
+		prefix:     joinPaths(r.prefix, prefix),
+		host:       r.host,
 	}
+}
 
-	existing, found := r.segments[seg.Path]
-	if found {
-		merged, err := mergeSegments(existing, seg)
-		if err != nil {
+func (r *router) Host(pattern string) Router {
+	return &router{
+		parent: r,
+		prefix: r.prefix,
+		host:   pattern,
+	}
+}
+
+// root gets the router at the top of the tree of groups.
+func (r *router) root() *router {
+	for r.parent != nil {
+		r = r.parent
+	}
+
+	return r
+}
+
+// scope returns Middleware that executes the Middleware of the group and its
+// parent groups, outermost first. The Middleware is looked up each time the
+// routes are compiled, so that Use works for routes that are already
+// registered.
+//
+// Like Use, it must only be called while holding the root router's lock.
+func (r *router) scope() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		for group := r; group.parent != nil; group = group.parent {
+			next = chain(next, group.middleware)
+		}
+
+		return next
+	}
+}
+
+func (r *router) URL(name string, params map[string]string, query url.Values) (string, error) {
+	template, found := r.currentTable().names[name]
+	if !found {
+		return "", fmt.Errorf("Unable to generate URL: no route is named %s", name)
+	}
+
+	return template.build(params, query)
+}
+
+func (r *router) Routes() []RouteInfo {
+	routes := []RouteInfo{}
+	r.Walk(func(route RouteInfo) error {
+		routes = append(routes, route)
+		return nil
+	})
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Host != routes[j].Host {
+			return routes[i].Host < routes[j].Host
+		}
+
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
+
+func (r *router) Walk(fn WalkFunc) error {
+	table := r.currentTable()
+	for _, routes := range table.hosts {
+		if err := walkRoutes(routes.host.pattern, routes.tree, fn); err != nil {
 			return err
 		}
+	}
 
-		r.segments[seg.Path] = merged
-	} else {
-		r.segments[seg.Path] = seg
+	return walkRoutes("", table.tree, fn)
+}
+
+// walkRoutes calls fn for every route in a Segment tree, in the order that
+// routes are matched.
+func walkRoutes(host string, tree *segment, fn WalkFunc) error {
+	for _, child := range tree.Children() {
+		err := child.walk("", nil, func(seg *segment, path string, params []string) error {
+			for _, endPt := range seg.Endpoints() {
+				if err := fn(newRouteInfo(endPt, host, path, params)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+func (r *router) Remove(method string, path string) error {
+	path, _ = r.scoped(path, nil)
+	return r.update(func(t *routeTable) error {
+		return t.remove(r.host, method, path, func(endPt endpoint) bool {
+			return true
+		})
+	})
+}
+
+func (r *router) Replace(method string, path string, fn HandlerFunc, opts ...RouteOption) error {
+	path, opts = r.scoped(path, opts)
+	replaced := conditions(newRouteConfig(opts).predicates)
+	return r.update(func(t *routeTable) error {
+		t.remove(r.host, method, path, func(endPt endpoint) bool {
+			return conditions(endPt.Predicates()) == replaced
+		})
+		return t.add(r.host, path, method, fn, opts)
+	})
+}
+
+func (r *router) addRoute(path string, method string, handler HandlerFunc, opts []RouteOption) error {
+	path, opts = r.scoped(path, opts)
+	return r.update(func(t *routeTable) error {
+		return t.add(r.host, path, method, handler, opts)
+	})
+}
+
+// scoped adds the path prefix and Middleware of a group to a route. Routes of
+// the router at the top of the groups are returned as they are.
+func (r *router) scoped(path string, opts []RouteOption) (string, []RouteOption) {
+	if r.parent == nil {
+		return path, opts
+	}
+
+	return joinPaths(r.prefix, path), append([]RouteOption{r.scope()}, opts...)
+}
+
+// newRouteInfo describes the route of an endpoint. The parameters, tags,
+// scopes and metadata are copied so that the RouteInfo can't be used to modify
+// the route.
+func newRouteInfo(endPt endpoint, host string, path string, params []string) RouteInfo {
+	settings := endPt.Settings()
+	info := RouteInfo{
+		Method: endPt.Method(),
+		Host:   host,
+		Path:   path,
+		Params: append([]string{}, params...),
+		Name:   settings.name,
+
+		Conditions: conditions(endPt.Predicates()),
+		Fallback:   endPt.Fallback(),
+
+		Description: settings.description,
+		Timeout:     settings.timeout,
+		MaxBodySize: settings.maxBodySize,
+		Deprecated:  settings.deprecated,
+	}
+
+	if len(settings.tags) > 0 {
+		info.Tags = append([]string{}, settings.tags...)
+	}
+
+	if len(settings.scopes) > 0 {
+		info.Scopes = append([]string{}, settings.scopes...)
+	}
+
+	if metadata := settings.metadata; metadata != nil {
+		info.Metadata = make(map[string]interface{}, len(metadata))
+		for key, value := range metadata {
+			info.Metadata[key] = value
+		}
+	}
+
+	return info
+}
+
+// joinPaths combines a path prefix with a path, ensuring that they are
+// separated by exactly one forward-slash.
+func joinPaths(prefix, path string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	path = strings.TrimPrefix(path, "/")
+
+	if path == "" {
+		return prefix
+	}
+
+	return prefix + "/" + path
+}
+
 func printLogo(addr string) {
 	const logo = `
       (_) |     
@@ -153,7 +668,7 @@ func printLogo(addr string) {
 |_| |_|_|_|\___|
 `
 
-	fmt.Println(logo)
+	fmt.Print(logo, "\n")
 	fmt.Println(formatAddress(addr))
 }
 
@@ -0,0 +1,836 @@
+package nile
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouterMiddlewareOrder(t *testing.T) {
+	var calls []string
+
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c Context) Response {
+				calls = append(calls, name)
+				return next(c)
+			}
+		}
+	}
+
+	handler := func(c Context) Response {
+		calls = append(calls, "handler")
+		return NewGenericResponse(http.StatusOK, map[string]string{"message": "hello"})
+	}
+
+	r := New()
+	if err := r.GET("/hello", handler, trace("route")); err != nil {
+		t.Fatalf("Router.GET(/hello) error, want <nil>, got %v", err)
+	}
+	r.Use(trace("first"), trace("second"))
+
+	w := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+
+	want := "first,second,route,handler"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("Middleware order, want %s, got %s", want, got)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("Response status, want %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestRouterMiddlewareShortCircuit(t *testing.T) {
+	var called bool
+
+	deny := func(next HandlerFunc) HandlerFunc {
+		return func(c Context) Response {
+			if c.Request().Header.Get("Authorization") == "" {
+				return NewBadRequest("10001", errors.New("Missing Authorization header"))
+			}
+			return next(c)
+		}
+	}
+
+	handler := func(c Context) Response {
+		called = true
+		return NewGenericResponse(http.StatusOK, map[string]string{"message": "hello"})
+	}
+
+	r := New()
+	r.Use(deny)
+	if err := r.GET("/hello", handler); err != nil {
+		t.Fatalf("Router.GET(/hello) error, want <nil>, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+
+	if called {
+		t.Error("Expected handler not to have been executed.")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Response status, want %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestRouterMiddlewareContextError(t *testing.T) {
+	var gotErr error
+
+	var inspect Middleware = func(next HandlerFunc) HandlerFunc {
+		return func(c Context) Response {
+			resp := next(c)
+			gotErr = c.Error()
+			return resp
+		}
+	}
+
+	handler := func(c Context) Response {
+		c.Param("missing")
+		return nil
+	}
+
+	r := New()
+	if err := r.GET("/hello", handler, inspect); err != nil {
+		t.Fatalf("Router.GET(/hello) error, want <nil>, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+
+	if gotErr == nil {
+		t.Error("Context.Error(), want error, got <nil>")
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Response status, want %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestRouterGroups(t *testing.T) {
+	var calls []string
+
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c Context) Response {
+				calls = append(calls, name)
+				return next(c)
+			}
+		}
+	}
+
+	handler := func(c Context) Response {
+		return NewGenericResponse(http.StatusOK, map[string]string{"message": "hello"})
+	}
+
+	r := New()
+	r.Use(trace("root"))
+	api := r.Group("/api/", trace("api"))
+	v1 := api.Group("v1")
+
+	if err := r.GET("/health", handler); err != nil {
+		t.Fatalf("Router.GET(/health) error, want <nil>, got %v", err)
+	}
+	if err := v1.GET("/products/:id", handler, trace("route")); err != nil {
+		t.Fatalf("Group.GET(/products/:id) error, want <nil>, got %v", err)
+	}
+	v1.Use(trace("v1"))
+
+	var tests = []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantCalls  string
+	}{
+		{http.MethodGet, "/health", http.StatusOK, "root"},
+		{http.MethodGet, "/api/v1/products/1", http.StatusOK, "root,api,v1,route"},
+		{http.MethodGet, "/products/1", http.StatusNotFound, "root"},
+		{http.MethodPost, "/api/v1/products/1", http.StatusMethodNotAllowed, "root"},
+		{http.MethodOptions, "/api/v1/products/1", http.StatusNoContent, "root"},
+	}
+
+	for _, test := range tests {
+		calls = nil
+
+		w := httptest.NewRecorder()
+		v1.(http.Handler).ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+		if w.Code != test.wantStatus {
+			t.Errorf("%s %s status, want %d, got %d", test.method, test.path, test.wantStatus, w.Code)
+		}
+		if got := strings.Join(calls, ","); got != test.wantCalls {
+			t.Errorf("%s %s middleware, want %s, got %s", test.method, test.path, test.wantCalls, got)
+		}
+	}
+}
+
+func TestRouterAutomaticMethods(t *testing.T) {
+	handler := func(c Context) Response {
+		return NewGenericResponse(http.StatusOK, map[string]string{"message": "hello"})
+	}
+
+	options := func(c Context) Response {
+		return NewGenericResponse(http.StatusOK, map[string]string{"message": "options"})
+	}
+
+	r := New()
+	routes := []struct {
+		register func(string, HandlerFunc, ...RouteOption) error
+		path     string
+		fn       HandlerFunc
+	}{
+		{r.GET, "/products", handler},
+		{r.POST, "/products", handler},
+		{r.DELETE, "/products/:id", handler},
+		{r.OPTIONS, "/products/:id", options},
+	}
+	for _, route := range routes {
+		if err := route.register(route.path, route.fn); err != nil {
+			t.Fatalf("Router registration of %s error, want <nil>, got %v", route.path, err)
+		}
+	}
+
+	getResp := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(getResp, httptest.NewRequest(http.MethodGet, "/products", nil))
+
+	var tests = []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+		wantBody   string
+	}{
+		{http.MethodHead, "/products", http.StatusOK, "", ""},
+		{http.MethodOptions, "/products", http.StatusNoContent, "GET, HEAD, OPTIONS, POST", ""},
+		{http.MethodPut, "/products", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, POST", ""},
+		{http.MethodHead, "/products/1", http.StatusMethodNotAllowed, "DELETE, OPTIONS", ""},
+		{http.MethodOptions, "/products/1", http.StatusOK, "", `{"message":"options"}`},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.(http.Handler).ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+
+		if w.Code != test.wantStatus {
+			t.Errorf("%s %s status, want %d, got %d", test.method, test.path, test.wantStatus, w.Code)
+		}
+		if got := w.Header().Get("Allow"); got != test.wantAllow {
+			t.Errorf("%s %s Allow header, want %q, got %q", test.method, test.path, test.wantAllow, got)
+		}
+		if test.method == http.MethodHead {
+			if w.Body.Len() != 0 {
+				t.Errorf("%s %s body, want empty, got %q", test.method, test.path, w.Body.String())
+			}
+			continue
+		}
+		if test.wantBody != "" && w.Body.String() != test.wantBody {
+			t.Errorf("%s %s body, want %s, got %s", test.method, test.path, test.wantBody, w.Body.String())
+		}
+	}
+
+	headResp := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(headResp, httptest.NewRequest(http.MethodHead, "/products", nil))
+	for _, header := range []string{"Content-Type", "Content-Length"} {
+		if want, got := getResp.Header().Get(header), headResp.Header().Get(header); want != got {
+			t.Errorf("HEAD /products %s header, want %q, got %q", header, want, got)
+		}
+	}
+}
+
+func TestRouterParamSiblings(t *testing.T) {
+	echo := func(names ...string) HandlerFunc {
+		return func(c Context) Response {
+			body := map[string]string{}
+			for _, name := range names {
+				body[name] = c.Param(name)
+			}
+			return NewGenericResponse(http.StatusOK, body)
+		}
+	}
+
+	r := New()
+	routes := []struct {
+		path string
+		fn   HandlerFunc
+	}{
+		{"/users/:userID/orders", echo("userID")},
+		{"/users/:id", echo("id")},
+		{"/products/:id<int>", echo("id")},
+		{"/products/:slug<[a-z-]+>", echo("slug")},
+	}
+	for _, route := range routes {
+		if err := r.GET(route.path, route.fn); err != nil {
+			t.Fatalf("Router.GET(%s) error, want <nil>, got %v", route.path, err)
+		}
+	}
+
+	var tests = []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/users/5/orders", http.StatusOK, `{"userID":"5"}`},
+		{"/users/5", http.StatusOK, `{"id":"5"}`},
+		{"/products/12", http.StatusOK, `{"id":"12"}`},
+		{"/products/red-shoes", http.StatusOK, `{"slug":"red-shoes"}`},
+		{"/products/Red", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if w.Code != test.wantStatus {
+			t.Errorf("GET %s status, want %d, got %d", test.path, test.wantStatus, w.Code)
+			continue
+		}
+		if test.wantBody != "" && w.Body.String() != test.wantBody {
+			t.Errorf("GET %s body, want %s, got %s", test.path, test.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestRouterURL(t *testing.T) {
+	handler := func(c Context) Response {
+		return NewGenericResponse(http.StatusOK, map[string]string{"message": "hello"})
+	}
+
+	r := New()
+	api := r.Group("/api")
+	routes := []struct {
+		register func(string, HandlerFunc, ...RouteOption) error
+		path     string
+		name     string
+	}{
+		{r.GET, "/", "home"},
+		{api.GET, "/products/:id<int>", "product"},
+		{api.GET, "/categories/:slug/products", "category-products"},
+		{r.GET, "/files/*path", "file"},
+	}
+	for _, route := range routes {
+		if err := route.register(route.path, handler, Name(route.name)); err != nil {
+			t.Fatalf("Router registration of %s error, want <nil>, got %v", route.path, err)
+		}
+	}
+
+	var tests = []struct {
+		name    string
+		params  map[string]string
+		query   url.Values
+		want    string
+		wantErr bool
+	}{
+		{"home", nil, nil, "/", false},
+		{"product", map[string]string{"id": "12"}, nil, "/api/products/12", false},
+		{"product", map[string]string{"id": "12"}, url.Values{"expand": {"reviews"}}, "/api/products/12?expand=reviews", false},
+		{"product", map[string]string{"id": "banana"}, nil, "", true},
+		{"product", map[string]string{}, nil, "", true},
+		{"category-products", map[string]string{"slug": "a b"}, nil, "/api/categories/a%20b/products", false},
+		{"file", map[string]string{"path": "docs/read me.txt"}, nil, "/files/docs/read%20me.txt", false},
+		{"missing", nil, nil, "", true},
+	}
+
+	for _, test := range tests {
+		got, err := api.URL(test.name, test.params, test.query)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Router.URL(%s, %v) error, want error %v, got %v", test.name, test.params, test.wantErr, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("Router.URL(%s, %v), want %s, got %s", test.name, test.params, test.want, got)
+		}
+	}
+
+	if err := r.POST("/products", handler, Name("product")); err == nil {
+		t.Error("Router.POST(/products) with a duplicate name, want error, got <nil>")
+	}
+
+	r.Host(":tenant<[a-z]+>.example.com").GET("/orders/:id", handler, Name("tenant-order"))
+	r.Host("admin.example.com").GET("/orders/:id", handler, Name("admin-order"))
+	r.Host(":region.example.org").GET("/status", handler, Name("region-status"))
+
+	var hostTests = []struct {
+		name    string
+		params  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"tenant-order", map[string]string{"tenant": "acme", "id": "1"}, "//acme.example.com/orders/1", false},
+		{"tenant-order", map[string]string{"id": "1"}, "", true},
+		{"tenant-order", map[string]string{"tenant": "acme1", "id": "1"}, "", true},
+		{"admin-order", map[string]string{"id": "1"}, "//admin.example.com/orders/1", false},
+		{"region-status", map[string]string{"region": "eu"}, "//eu.example.org/status", false},
+		{"region-status", map[string]string{"region": "evil.com/x"}, "", true},
+	}
+
+	for _, test := range hostTests {
+		got, err := r.URL(test.name, test.params, nil)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Router.URL(%s, %v) error, want error %v, got %v", test.name, test.params, test.wantErr, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("Router.URL(%s, %v), want %s, got %s", test.name, test.params, test.want, got)
+		}
+	}
+}
+
+func TestContextURL(t *testing.T) {
+	r := New()
+	r.GET("/products/:id", func(c Context) Response {
+		url, err := c.URL("product", map[string]string{"id": "7"}, nil)
+		if err != nil {
+			return nil
+		}
+		return NewGenericResponse(http.StatusOK, map[string]string{"location": url})
+	}, Name("product"))
+
+	w := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+
+	want := `{"location":"/products/7"}`
+	if w.Body.String() != want {
+		t.Errorf("Context.URL(product) body, want %s, got %s", want, w.Body.String())
+	}
+}
+
+func TestRouterRoutes(t *testing.T) {
+	handler := func(c Context) Response {
+		return NewGenericResponse(http.StatusOK, map[string]string{"message": "hello"})
+	}
+
+	r := New()
+	api := r.Group("/api")
+	api.POST("/products", handler)
+	api.GET("/products/:id<int>", handler, Name("product"), Meta("owner", "catalog"))
+	api.GET("/products", handler)
+	r.GET("/files/*path", handler)
+	r.GET("/", handler)
+	api.DELETE("/products/:id<int>", handler)
+
+	want := []RouteInfo{
+		{Method: http.MethodGet, Path: "/", Params: []string{}, Fallback: true},
+		{Method: http.MethodGet, Path: "/api/products", Params: []string{}, Fallback: true},
+		{Method: http.MethodPost, Path: "/api/products", Params: []string{}, Fallback: true},
+		{Method: http.MethodDelete, Path: "/api/products/:id<int>", Params: []string{"id"}, Fallback: true},
+		{Method: http.MethodGet, Path: "/api/products/:id<int>", Params: []string{"id"}, Name: "product", Metadata: map[string]interface{}{"owner": "catalog"}, Fallback: true},
+		{Method: http.MethodGet, Path: "/files/*path", Params: []string{"path"}, Fallback: true},
+	}
+
+	got := r.Routes()
+	if len(got) != len(want) {
+		t.Fatalf("len(Router.Routes()), want %d, got %d: %+v", len(want), len(got), got)
+	}
+
+	for idx := range want {
+		if !reflect.DeepEqual(want[idx], got[idx]) {
+			t.Errorf("Router.Routes()[%d], want %+v, got %+v", idx, want[idx], got[idx])
+		}
+	}
+
+	var walked int
+	stop := errors.New("stop")
+	err := r.Walk(func(route RouteInfo) error {
+		walked++
+		if walked == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || walked != 2 {
+		t.Errorf("Router.Walk() stopping early, want (%v, 2), got (%v, %d)", stop, err, walked)
+	}
+}
+
+func TestRouterPathPolicy(t *testing.T) {
+	var tests = []struct {
+		policy       PathPolicy
+		method       string
+		path         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{PathIgnoreTrailingSlash, http.MethodGet, "/hello", http.StatusOK, ""},
+		{PathIgnoreTrailingSlash, http.MethodGet, "/hello/", http.StatusOK, ""},
+		{PathIgnoreTrailingSlash, http.MethodGet, "/a/../hello", http.StatusNotFound, ""},
+		{PathServeCanonical, http.MethodGet, "//hello//", http.StatusOK, ""},
+		{PathServeCanonical, http.MethodGet, "/a/./../hello", http.StatusOK, ""},
+		{PathServeCanonical, http.MethodGet, "/hello/world", http.StatusNotFound, ""},
+		{PathRedirectCanonical, http.MethodGet, "/hello", http.StatusOK, ""},
+		{PathRedirectCanonical, http.MethodGet, "/hello/?page=2", http.StatusMovedPermanently, "/hello?page=2"},
+		{PathRedirectCanonical, http.MethodPost, "//hello", http.StatusPermanentRedirect, "/hello"},
+		{PathRejectNonCanonical, http.MethodGet, "/hello", http.StatusOK, ""},
+		{PathRejectNonCanonical, http.MethodGet, "/hello/", http.StatusNotFound, ""},
+		{PathRejectNonCanonical, http.MethodGet, "/a/../hello", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		r := New(WithPathPolicy(test.policy))
+		r.GET("/hello", namedHandler("hello"))
+		r.POST("/hello", namedHandler("hello"))
+
+		// Set the path directly, since a path beginning with // would be
+		// parsed as a host.
+		req := httptest.NewRequest(test.method, "/", nil)
+		req.URL.Path, req.URL.RawQuery, _ = strings.Cut(test.path, "?")
+
+		w := httptest.NewRecorder()
+		r.(http.Handler).ServeHTTP(w, req)
+
+		if w.Code != test.wantStatus {
+			t.Errorf("Policy %d: %s %s status, want %d, got %d", test.policy, test.method, test.path, test.wantStatus, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != test.wantLocation {
+			t.Errorf("Policy %d: %s %s Location, want %q, got %q", test.policy, test.method, test.path, test.wantLocation, got)
+		}
+	}
+}
+
+func TestRouterEscapedPaths(t *testing.T) {
+	echo := func(names ...string) HandlerFunc {
+		return func(c Context) Response {
+			body := map[string]string{}
+			for _, name := range names {
+				body[name] = c.Param(name)
+			}
+			return NewGenericResponse(http.StatusOK, body)
+		}
+	}
+
+	r := New()
+	r.GET("/files/:name", echo("name"), Name("file"))
+	r.GET("/users/:id/files/:name", echo("id", "name"))
+	r.GET("/static/*path", echo("path"))
+	r.GET("/café/:id", echo("id"))
+	r.GET("/docs/:dir/:name<[a-zé]+>", echo("dir", "name"))
+	r.GET("/discounts/100%", echo())
+
+	var tests = []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/files/a%2Fb", http.StatusOK, `{"name":"a/b"}`},
+		{"/files/caf%C3%A9", http.StatusOK, `{"name":"café"}`},
+		{"/files/a%2Fb%20c", http.StatusOK, `{"name":"a/b c"}`},
+		{"/users/a%2F1/files/x%2Fy", http.StatusOK, `{"id":"a/1","name":"x/y"}`},
+		{"/static/a/b%2Fc", http.StatusOK, `{"path":"a/b/c"}`},
+		{"/files/a/b", http.StatusNotFound, ""},
+		{"/caf%C3%A9/ab", http.StatusOK, `{"id":"ab"}`},
+		{"/caf%C3%A9/a%2Fb", http.StatusOK, `{"id":"a/b"}`},
+		{"/docs/x/%C3%A9", http.StatusOK, `{"dir":"x","name":"é"}`},
+		{"/docs/a%2Fb/%C3%A9", http.StatusOK, `{"dir":"a/b","name":"é"}`},
+		{"/docs/a%2Fb/%C3%A91", http.StatusNotFound, ""},
+		{"/discounts/100%25", http.StatusOK, `{}`},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if w.Code != test.wantStatus {
+			t.Errorf("GET %s status, want %d, got %d", test.path, test.wantStatus, w.Code)
+			continue
+		}
+		if test.wantBody != "" && w.Body.String() != test.wantBody {
+			t.Errorf("GET %s body, want %s, got %s", test.path, test.wantBody, w.Body.String())
+		}
+	}
+
+	// A generated URL should route back to the same parameter value.
+	location, err := r.URL("file", map[string]string{"name": "a/b"}, nil)
+	if err != nil {
+		t.Fatalf("Router.URL(file) error, want <nil>, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, location, nil))
+	if want := `{"name":"a/b"}`; w.Body.String() != want {
+		t.Errorf("GET %s body, want %s, got %s", location, want, w.Body.String())
+	}
+
+	// Redirects to the canonical path should keep the encoding of the path.
+	r = New(WithPathPolicy(PathRedirectCanonical))
+	r.GET("/café/:id", echo("id"))
+
+	w = httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/caf%C3%A9/./a%2Fb%20c/", nil))
+	if want := "/caf%C3%A9/a%2Fb%20c"; w.Header().Get("Location") != want {
+		t.Errorf("GET /caf%%C3%%A9/./a%%2Fb%%20c/ Location, want %s, got %s", want, w.Header().Get("Location"))
+	}
+}
+
+func TestRouterHosts(t *testing.T) {
+	echo := func(names ...string) HandlerFunc {
+		return func(c Context) Response {
+			body := map[string]string{}
+			for _, name := range names {
+				body[name] = c.Param(name)
+			}
+			return NewGenericResponse(http.StatusOK, body)
+		}
+	}
+
+	r := New()
+	r.GET("/products/:id", echo("id"))
+	tenant := r.Host(":tenant<[a-z]+>.api.example.com")
+	tenant.Group("/v1").GET("/products/:id", echo("tenant", "id"))
+	r.Host(":tenant.example.com").GET("/products/:id", echo("tenant", "id"))
+	r.Host(":tenant<[a-z]+>.example.com").GET("/products/:id", namedHandler("letters"))
+	r.Host("admin.example.com").GET("/products/:id", namedHandler("admin"))
+
+	if err := r.Host("bad..example.com").GET("/", namedHandler("bad")); err == nil {
+		t.Error("Router.Host(bad..example.com).GET(/) error, want error, got <nil>")
+	}
+
+	var tests = []struct {
+		host       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"acme.api.example.com", "/v1/products/1", http.StatusOK, `{"id":"1","tenant":"acme"}`},
+		{"acme.API.Example.com:8080", "/v1/products/1", http.StatusOK, `{"id":"1","tenant":"acme"}`},
+		{"acme.api.example.com", "/products/1", http.StatusOK, `{"id":"1"}`},
+		{"acme1.api.example.com", "/v1/products/1", http.StatusNotFound, ""},
+		{"a.b.api.example.com", "/v1/products/1", http.StatusNotFound, ""},
+		{"admin.example.com", "/products/1", http.StatusOK, `"admin"`},
+		{"shop.example.com", "/products/1", http.StatusOK, `"letters"`},
+		{"shop1.example.com", "/products/1", http.StatusOK, `{"id":"1","tenant":"shop1"}`},
+		{"example.com", "/products/1", http.StatusOK, `{"id":"1"}`},
+		{"[::1]:8080", "/products/1", http.StatusOK, `{"id":"1"}`},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.Host = test.host
+
+		w := httptest.NewRecorder()
+		r.(http.Handler).ServeHTTP(w, req)
+
+		if w.Code != test.wantStatus {
+			t.Errorf("GET %s%s status, want %d, got %d", test.host, test.path, test.wantStatus, w.Code)
+			continue
+		}
+		if test.wantBody != "" && w.Body.String() != test.wantBody {
+			t.Errorf("GET %s%s body, want %s, got %s", test.host, test.path, test.wantBody, w.Body.String())
+		}
+	}
+
+	var hosts []string
+	r.Walk(func(route RouteInfo) error {
+		hosts = append(hosts, route.Host)
+		return nil
+	})
+
+	want := []string{":tenant<[a-z]+>.api.example.com", "admin.example.com", ":tenant<[a-z]+>.example.com", ":tenant.example.com", ""}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("Router.Walk() hosts, want %v, got %v", want, hosts)
+	}
+}
+
+func TestRouterPredicates(t *testing.T) {
+	r := New()
+	r.GET("/products", namedHandler("v1"))
+	r.GET("/products", namedHandler("v2"), MatchAccept("application/vnd.acme.v2+json"))
+	r.GET("/products", namedHandler("beta"), MatchHeader("X-Beta", ""))
+	r.GET("/orders", namedHandler("v2"), MatchAccept("application/vnd.acme.v2+json"))
+	r.GET("/orders", namedHandler("preview"), MatchQuery("preview", "true"))
+
+	var errTests = []struct {
+		opts []RouteOption
+	}{
+		{[]RouteOption{MatchAccept("application/vnd.acme.v2+json")}},
+		{nil},
+		{[]RouteOption{MatchQuery("v", "3"), Fallback()}},
+	}
+
+	for _, test := range errTests {
+		if err := r.GET("/products", namedHandler("duplicate"), test.opts...); err == nil {
+			t.Errorf("Router.GET(/products, %d options) error, want error, got <nil>", len(test.opts))
+		}
+	}
+
+	var tests = []struct {
+		method     string
+		path       string
+		header     string
+		value      string
+		wantStatus int
+		wantBody   string
+		wantVary   []string
+	}{
+		{http.MethodGet, "/products", "", "", http.StatusOK, `"v1"`, []string{"Accept", "X-Beta"}},
+		{http.MethodGet, "/products", "Accept", "application/vnd.acme.v2+json; charset=utf-8", http.StatusOK, `"v2"`, []string{"Accept", "X-Beta"}},
+		{http.MethodGet, "/products", "Accept", "text/html, application/vnd.acme.v2+json", http.StatusOK, `"v2"`, []string{"Accept", "X-Beta"}},
+		{http.MethodGet, "/products", "Accept", "*/*", http.StatusOK, `"v1"`, []string{"Accept", "X-Beta"}},
+		{http.MethodGet, "/products", "X-Beta", "1", http.StatusOK, `"beta"`, []string{"Accept", "X-Beta"}},
+		{http.MethodHead, "/products", "Accept", "application/vnd.acme.v2+json", http.StatusOK, "", []string{"Accept", "X-Beta"}},
+		{http.MethodGet, "/orders", "Accept", "application/vnd.acme.v2+json", http.StatusOK, `"v2"`, []string{"Accept"}},
+		{http.MethodGet, "/orders?preview=true", "", "", http.StatusOK, `"preview"`, []string{"Accept"}},
+		{http.MethodGet, "/orders", "Accept", "application/vnd.acme.v3+json", http.StatusNotAcceptable, "", []string{"Accept"}},
+		{http.MethodHead, "/orders", "", "", http.StatusNotAcceptable, "", []string{"Accept"}},
+		{http.MethodPost, "/orders", "", "", http.StatusMethodNotAllowed, "", nil},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+
+		w := httptest.NewRecorder()
+		r.(http.Handler).ServeHTTP(w, req)
+
+		if w.Code != test.wantStatus {
+			t.Errorf("%s %s with %s: %s status, want %d, got %d", test.method, test.path, test.header, test.value, test.wantStatus, w.Code)
+			continue
+		}
+		if test.wantBody != "" && w.Body.String() != test.wantBody {
+			t.Errorf("%s %s with %s: %s body, want %s, got %s", test.method, test.path, test.header, test.value, test.wantBody, w.Body.String())
+		}
+		if got := w.Header().Values("Vary"); !reflect.DeepEqual(got, test.wantVary) {
+			t.Errorf("%s %s with %s: %s Vary header, want %v, got %v", test.method, test.path, test.header, test.value, test.wantVary, got)
+		}
+	}
+
+	if err := r.Replace(http.MethodGet, "/products", namedHandler("v2.1"), MatchAccept("application/vnd.acme.v2+json")); err != nil {
+		t.Fatalf("Router.Replace(GET, /products) error, want <nil>, got %v", err)
+	}
+
+	if got := len(r.Routes()); got != 5 {
+		t.Errorf("len(Router.Routes()) after Replace, want 5, got %d", got)
+	}
+
+	if err := r.Remove(http.MethodGet, "/orders"); err != nil {
+		t.Fatalf("Router.Remove(GET, /orders) error, want <nil>, got %v", err)
+	}
+
+	if got := len(r.Routes()); got != 3 {
+		t.Errorf("len(Router.Routes()) after Remove, want 3, got %d", got)
+	}
+}
+
+func TestRouterCustomErrorHandlers(t *testing.T) {
+	r := New(
+		WithNotFound(func(c Context) Response {
+			return NewNotFoundError("10404", errors.New("Nothing at "+c.Path()))
+		}),
+		WithMethodNotAllowed(func(c Context) Response {
+			return NewGenericResponse(http.StatusMethodNotAllowed, c.AllowedMethods())
+		}),
+		WithPathPolicy(PathRejectNonCanonical),
+	)
+	r.GET("/products/:id", namedHandler("show"))
+	r.DELETE("/products/:id", namedHandler("delete"))
+
+	var tests = []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{http.MethodGet, "/orders/1", http.StatusNotFound, `{"code":"10404","message":"Nothing at /orders/1","status":404}`},
+		{http.MethodGet, "/products//1", http.StatusNotFound, `{"code":"10404","message":"Nothing at /products//1","status":404}`},
+		{http.MethodPost, "/products/1", http.StatusMethodNotAllowed, `["DELETE","GET","HEAD","OPTIONS"]`},
+		{http.MethodGet, "/products/1", http.StatusOK, `"show"`},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/", nil)
+		req.URL.Path = test.path
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != test.wantStatus {
+			t.Errorf("%s %s status, want %d, got %d", test.method, test.path, test.wantStatus, w.Code)
+		}
+		if w.Body.String() != test.wantBody {
+			t.Errorf("%s %s body, want %s, got %s", test.method, test.path, test.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestRouterRouteOptions(t *testing.T) {
+	deprecated := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	var seen RouteInfo
+	r := New()
+	r.Use(func(next HandlerFunc) HandlerFunc {
+		return func(c Context) Response {
+			seen = c.Route()
+			return next(c)
+		}
+	})
+
+	r.Group("/api").POST("/products/:id", func(c Context) Response {
+		if _, hasDeadline := c.Request().Context().Deadline(); !hasDeadline {
+			return NewGenericResponse(http.StatusInternalServerError, "no deadline")
+		}
+		if _, err := io.ReadAll(c.Request().Body); err != nil {
+			return NewGenericResponse(http.StatusRequestEntityTooLarge, "too large")
+		}
+		return NewGenericResponse(http.StatusOK, "ok")
+	},
+		Name("update-product"),
+		Describe("Updates a product"),
+		Tags("products", "catalog"),
+		Scopes("products:write"),
+		Timeout(time.Second),
+		MaxBodySize(8),
+		Deprecated(deprecated),
+		Meta("owner", "catalog"),
+	)
+
+	want := RouteInfo{
+		Method:      http.MethodPost,
+		Path:        "/api/products/:id",
+		Params:      []string{"id"},
+		Name:        "update-product",
+		Fallback:    true,
+		Description: "Updates a product",
+		Tags:        []string{"products", "catalog"},
+		Scopes:      []string{"products:write"},
+		Timeout:     time.Second,
+		MaxBodySize: 8,
+		Deprecated:  deprecated,
+		Metadata:    map[string]interface{}{"owner": "catalog"},
+	}
+
+	if got := r.Routes(); len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+		t.Errorf("Router.Routes(), want [%+v], got %+v", want, got)
+	}
+
+	var tests = []struct {
+		body       string
+		wantStatus int
+	}{
+		{"{}", http.StatusOK},
+		{`{"name":"too long"}`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		seen = RouteInfo{}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/products/1", strings.NewReader(test.body)))
+
+		if w.Code != test.wantStatus {
+			t.Errorf("POST /api/products/1 with %s status, want %d, got %d", test.body, test.wantStatus, w.Code)
+		}
+		if !reflect.DeepEqual(seen, want) {
+			t.Errorf("Context.Route(), want %+v, got %+v", want, seen)
+		}
+		if got := w.Header().Get("Deprecation"); got != "@1709251200" {
+			t.Errorf("POST /api/products/1 Deprecation header, want @1709251200, got %s", got)
+		}
+	}
+}
@@ -3,6 +3,9 @@ package nile
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -14,8 +17,17 @@ type segment struct {
 	Path       string
 	children   map[string]*segment
 	childOrder []string
-	paramChild *segment
-	endpoints  map[string]endpoint
+	// paramChildren are the parameter segments under the current path. There
+	// may be several when routes use different names or constraints for a
+	// parameter at the same position. Constrained parameters are kept ahead of
+	// unconstrained ones.
+	paramChildren []*segment
+	// wildcardChild is a segment that captures the remainder of the path,
+	// including any forward-slashes.
+	wildcardChild *segment
+	endpoints     map[string][]endpoint
+	// constraint restricts the values that a parameter segment matches.
+	constraint *regexp.Regexp
 }
 
 // newSegment accepts a path and creates a new Segment.
@@ -25,7 +37,7 @@ func newSegment(path string) *segment {
 		Path:       head,
 		children:   map[string]*segment{},
 		childOrder: []string{},
-		endpoints:  map[string]endpoint{},
+		endpoints:  map[string][]endpoint{},
 	}
 
 	if tail != "" {
@@ -37,16 +49,30 @@ func newSegment(path string) *segment {
 
 // newSegmentEndpoint creates a Segment and attaches an Endpoint at the leaf
 // node.
-func newSegmentEndpoint(path string, method string, handler HandlerFunc) (*segment, error) {
+func newSegmentEndpoint(path string, method string, handler HandlerFunc, opts ...RouteOption) (*segment, error) {
 	head, tail := splitPath(path)
 	seg := &segment{
 		Path:      head,
 		children:  map[string]*segment{},
-		endpoints: map[string]endpoint{},
+		endpoints: map[string][]endpoint{},
+	}
+
+	if isParam(head) && hasConstraint(head) {
+		_, constraint := splitParam(head)
+		re, err := compileConstraint(constraint)
+		if err != nil {
+			return nil, err
+		}
+
+		seg.constraint = re
 	}
 
 	if tail != "" {
-		child, err := newSegmentEndpoint(tail, method, handler)
+		if isWildcard(head) {
+			return nil, fmt.Errorf("Wildcard segment %s must be the last segment in the path", head)
+		}
+
+		child, err := newSegmentEndpoint(tail, method, handler, opts...)
 		if err != nil {
 			return nil, err
 		}
@@ -55,7 +81,7 @@ func newSegmentEndpoint(path string, method string, handler HandlerFunc) (*segme
 			return nil, err
 		}
 	} else {
-		endPt, err := newEndpoint(method, handler)
+		endPt, err := newEndpoint(method, handler, opts...)
 		if err != nil {
 			return nil, err
 		}
@@ -69,49 +95,43 @@ func newSegmentEndpoint(path string, method string, handler HandlerFunc) (*segme
 }
 
 // mergeSegments combines two different segments and returns the combined object.
-// It assumes that the root of each segment have identical paths.
+// It assumes that the root of each segment have identical paths. Children that
+// both segments have are merged into the children of the first.
 func mergeSegments(first, second *segment) (*segment, error) {
 	if first.Path != second.Path {
 		return nil, errors.New("May only merge segments with the same path")
 	}
 
 	merged := newSegment(first.Path)
+	merged.constraint = first.constraint
 
-	addChildren := func(children []*segment) error {
-		for _, child := range children {
-			if err := merged.AddChild(child); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	}
-
-	addEndpoints := func(endpoints []endpoint) error {
-		for _, endpoint := range endpoints {
-			if err := merged.AddEndpoint(endpoint); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	}
-
-	if err := addChildren(first.Children()); err != nil {
+	if err := merged.merge(first); err != nil {
 		return nil, err
 	}
-	if err := addChildren(second.Children()); err != nil {
+	if err := merged.merge(second); err != nil {
 		return nil, err
 	}
 
-	if err := addEndpoints(first.Endpoints()); err != nil {
-		return nil, err
+	return merged, nil
+}
+
+// merge adds the children and Endpoints of another Segment with the same path
+// to the Segment. Unlike mergeSegments, it modifies the Segment in place, so
+// adding a route only costs as much as the length of its path.
+func (s *segment) merge(other *segment) error {
+	for _, child := range other.Children() {
+		if err := s.AddChild(child); err != nil {
+			return err
+		}
 	}
-	if err := addEndpoints(second.Endpoints()); err != nil {
-		return nil, err
+
+	for _, endPt := range other.Endpoints() {
+		if err := s.AddEndpoint(endPt); err != nil {
+			return err
+		}
 	}
 
-	return merged, nil
+	return nil
 }
 
 // Children gets the list of paths that exist under the current path.
@@ -121,54 +141,93 @@ func (s *segment) Children() []*segment {
 		children[idx] = s.children[childPath]
 	}
 
-	if s.paramChild != nil {
-		children = append(children, s.paramChild)
+	children = append(children, s.paramChildren...)
+
+	if s.wildcardChild != nil {
+		children = append(children, s.wildcardChild)
 	}
 
 	return children
 }
 
-// AddChild adds a child path that should exist under the current path.
+// AddChild adds a child path that should exist under the current path. If
+// there already is a child with the same path, the new child is merged into
+// it.
 func (s *segment) AddChild(child *segment) error {
 	if isParam(child.Path) {
-		if s.paramChild != nil {
-			return fmt.Errorf("Segment %s already has a route with a parameter", s.Path)
+		for _, paramChild := range s.paramChildren {
+			if paramChild.Path == child.Path {
+				return paramChild.merge(child)
+			}
+		}
+
+		// Constrained parameters go after the existing constrained parameters,
+		// but ahead of any unconstrained ones.
+		idx := len(s.paramChildren)
+		if hasConstraint(child.Path) {
+			for pos, paramChild := range s.paramChildren {
+				if !hasConstraint(paramChild.Path) {
+					idx = pos
+					break
+				}
+			}
 		}
 
-		s.paramChild = child
+		s.paramChildren = append(s.paramChildren, nil)
+		copy(s.paramChildren[idx+1:], s.paramChildren[idx:])
+		s.paramChildren[idx] = child
 		return nil
 	}
 
-	if currentChild, exists := s.children[child.Path]; exists {
-		merged, err := mergeSegments(currentChild, child)
-		if err != nil {
-			return err
+	if isWildcard(child.Path) {
+		if s.wildcardChild == nil {
+			s.wildcardChild = child
+			return nil
 		}
 
-		s.children[child.Path] = merged
-		return nil
+		if s.wildcardChild.Path != child.Path {
+			return fmt.Errorf("Segment %s already has a route with a wildcard", s.Path)
+		}
+
+		return s.wildcardChild.merge(child)
+	}
+
+	if currentChild, exists := s.children[child.Path]; exists {
+		return currentChild.merge(child)
 	}
 
 	s.children[child.Path] = child
 
-	for idx, childPath := range s.childOrder {
-		if strings.Compare(child.Path, childPath) == 1 {
-			s.childOrder = append(s.childOrder, "")
-			copy(s.childOrder[idx+1:], s.childOrder[idx:])
-			s.childOrder[idx] = child.Path
+	// childOrder is sorted in descending order.
+	idx := sort.Search(len(s.childOrder), func(idx int) bool {
+		return s.childOrder[idx] < child.Path
+	})
 
-			return nil
-		}
-	}
-
-	s.childOrder = append(s.childOrder, child.Path)
+	s.childOrder = append(s.childOrder, "")
+	copy(s.childOrder[idx+1:], s.childOrder[idx:])
+	s.childOrder[idx] = child.Path
 	return nil
 }
 
 // RemoveChild removes a child path from the current path.
 func (s *segment) RemoveChild(path string) error {
 	if isParam(path) {
-		s.paramChild = nil
+		for idx, paramChild := range s.paramChildren {
+			if paramChild.Path == path {
+				s.paramChildren = append(s.paramChildren[:idx], s.paramChildren[idx+1:]...)
+				return nil
+			}
+		}
+
+		return fmt.Errorf("Unable to remove child %s from segment %s: child does not exist", path, s.Path)
+	}
+
+	if isWildcard(path) {
+		if s.wildcardChild == nil || s.wildcardChild.Path != path {
+			return fmt.Errorf("Unable to remove child %s from segment %s: child does not exist", path, s.Path)
+		}
+
+		s.wildcardChild = nil
 		return nil
 	}
 
@@ -177,78 +236,204 @@ func (s *segment) RemoveChild(path string) error {
 	}
 
 	delete(s.children, path)
+	for idx, childPath := range s.childOrder {
+		if childPath == path {
+			s.childOrder = append(s.childOrder[:idx], s.childOrder[idx+1:]...)
+			break
+		}
+	}
+
 	return nil
 }
 
-// Endpoint gets the Endpoint that matches an HTTP method.
-func (s *segment) Endpoint(method string) (endpoint, bool) {
-	endpoint, found := s.endpoints[method]
-	return endpoint, found
+// child gets the child Segment with exactly the given path, treating
+// parameters and wildcards like any other path.
+func (s *segment) child(path string) (*segment, bool) {
+	switch {
+	case isParam(path):
+		for _, paramChild := range s.paramChildren {
+			if paramChild.Path == path {
+				return paramChild, true
+			}
+		}
+	case isWildcard(path):
+		if s.wildcardChild != nil && s.wildcardChild.Path == path {
+			return s.wildcardChild, true
+		}
+	default:
+		child, exists := s.children[path]
+		return child, exists
+	}
+
+	return nil, false
 }
 
-// Endpoints gets the list of HTTP endpoints that resolve exactly at this
-// path.
-func (s *segment) Endpoints() []endpoint {
-	endpoints := make([]endpoint, len(s.endpoints))
-	idx := 0
+// RemoveEndpoint removes the Endpoints for an HTTP method from the descendant
+// of the Segment at a path template, such as /products/:id. Only the Endpoints
+// for which remove returns true are removed. Any Segments that are left without
+// endpoints or children are removed as well. It returns the removed Endpoints.
+func (s *segment) RemoveEndpoint(path string, method string, remove func(endPt endpoint) bool) []endpoint {
+	head, tail := splitPath(path)
+	child, exists := s.child(head)
+	if !exists {
+		return nil
+	}
+
+	var removed []endpoint
+	if tail == "" {
+		var kept []endpoint
+		for _, endPt := range child.endpoints[method] {
+			if remove(endPt) {
+				removed = append(removed, endPt)
+			} else {
+				kept = append(kept, endPt)
+			}
+		}
 
-	for _, endPt := range s.endpoints {
-		endpoints[idx] = endPt
-		idx++
+		if len(kept) > 0 {
+			child.endpoints[method] = kept
+		} else {
+			delete(child.endpoints, method)
+		}
+	} else {
+		removed = child.RemoveEndpoint(tail, method, remove)
 	}
 
-	return endpoints
+	if len(removed) > 0 && len(child.endpoints) == 0 && len(child.Children()) == 0 {
+		s.RemoveChild(head)
+	}
+
+	return removed
 }
 
-// AddEndpoint adds an Endpoint to the Segment.
-func (s *segment) AddEndpoint(endPt endpoint) error {
-	if _, exists := s.endpoints[endPt.Method()]; exists {
-		return fmt.Errorf("Unable to add %s endpoint to segment %s: endpoint already exists", endPt.Method(), s.Path)
+// clone creates a deep copy of the Segment and its descendants. Endpoints are
+// shared, since they are never modified.
+func (s *segment) clone() *segment {
+	seg := &segment{
+		Path:       s.Path,
+		children:   make(map[string]*segment, len(s.children)),
+		childOrder: append([]string{}, s.childOrder...),
+		endpoints:  make(map[string][]endpoint, len(s.endpoints)),
+		constraint: s.constraint,
 	}
 
-	s.endpoints[endPt.Method()] = endPt
-	return nil
+	for path, child := range s.children {
+		seg.children[path] = child.clone()
+	}
+
+	for _, paramChild := range s.paramChildren {
+		seg.paramChildren = append(seg.paramChildren, paramChild.clone())
+	}
+
+	if s.wildcardChild != nil {
+		seg.wildcardChild = s.wildcardChild.clone()
+	}
+
+	for method, endpoints := range s.endpoints {
+		seg.endpoints[method] = append([]endpoint{}, endpoints...)
+	}
+
+	return seg
 }
 
-// Matches checks a path against the current Segment's endpoints.
-// If a match doesn't exist, it checks against the Segment's children.
-func (s *segment) Matches(path string) (*match, bool) {
-	head, tail := splitPath(path)
+// Endpoint gets the Endpoint that matches an HTTP method. When there are
+// several, the fallback is preferred, followed by the first to be added.
+func (s *segment) Endpoint(method string) (endpoint, bool) {
+	endpoints := s.endpoints[method]
+	for _, endPt := range endpoints {
+		if endPt.Fallback() {
+			return endPt, true
+		}
+	}
 
-	if head != s.Path && !isParam(s.Path) {
+	if len(endpoints) == 0 {
 		return nil, false
 	}
 
-	if tail == "" {
-		match := newMatch(s, path)
-		if isParam(s.Path) {
-			match.AddParam(s.Path[1:], head)
+	return endpoints[0], true
+}
+
+// Endpoints gets the list of HTTP endpoints that resolve exactly at this
+// path, sorted by HTTP method. Endpoints with the same method are in the
+// order that they were added.
+func (s *segment) Endpoints() []endpoint {
+	var endpoints []endpoint
+	for _, methodEndpoints := range s.endpoints {
+		endpoints = append(endpoints, methodEndpoints...)
+	}
+
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].Method() < endpoints[j].Method()
+	})
+
+	return endpoints
+}
+
+// walk calls fn for the Segment and each of its descendants, in the order that
+// they are matched. The path template and parameter names leading up to, but
+// not including, the Segment are passed as path and params.
+func (s *segment) walk(path string, params []string, fn func(seg *segment, path string, params []string) error) error {
+	path += "/" + s.Path
+	if isParam(s.Path) || isWildcard(s.Path) {
+		params = append(params[:len(params):len(params)], s.ParamName())
+	}
+
+	if err := fn(s, path, params); err != nil {
+		return err
+	}
+
+	for _, child := range s.Children() {
+		if err := child.walk(path, params, fn); err != nil {
+			return err
 		}
-		return match, true
 	}
 
-	// Check the children
-	for _, child := range s.children {
-		match, matches := child.Matches(tail)
-		if matches {
-			match.RequestURI = path
-			if isParam(s.Path) {
-				match.AddParam(s.Path[1:], head)
-			}
-			return match, matches
+	return nil
+}
+
+// AllowedMethods gets the sorted list of HTTP methods that the Segment responds
+// to. This includes HEAD when there is a GET endpoint, and OPTIONS, since both
+// are handled automatically.
+func (s *segment) AllowedMethods() []string {
+	methods := []string{http.MethodOptions}
+	for method := range s.endpoints {
+		if method != http.MethodOptions {
+			methods = append(methods, method)
 		}
 	}
 
-	if s.paramChild != nil {
-		match, matches := s.paramChild.Matches(tail)
-		if matches {
-			match.RequestURI = path
-			match.AddParam(s.Path[1:], head)
-			return match, matches
+	_, hasGet := s.endpoints[http.MethodGet]
+	_, hasHead := s.endpoints[http.MethodHead]
+	if hasGet && !hasHead {
+		methods = append(methods, http.MethodHead)
+	}
+
+	sort.Strings(methods)
+	return methods
+}
+
+// AddEndpoint adds an Endpoint to the Segment. Several Endpoints may be added
+// for the same HTTP method if they have different predicates, but only one of
+// them may be the fallback.
+func (s *segment) AddEndpoint(endPt endpoint) error {
+	for _, existing := range s.endpoints[endPt.Method()] {
+		switch {
+		case conditions(existing.Predicates()) == conditions(endPt.Predicates()):
+			return fmt.Errorf("Unable to add %s endpoint to segment %s: endpoint already exists", endPt.Method(), s.Path)
+		case existing.Fallback() && endPt.Fallback():
+			return fmt.Errorf("Unable to add %s endpoint to segment %s: fallback endpoint already exists", endPt.Method(), s.Path)
 		}
 	}
 
-	return nil, false
+	s.endpoints[endPt.Method()] = append(s.endpoints[endPt.Method()], endPt)
+	return nil
+}
+
+// ParamName gets the name of the parameter that is captured by a parameter or
+// wildcard Segment, without any constraint.
+func (s *segment) ParamName() string {
+	name, _ := splitParam(s.Path)
+	return name
 }
 
 func isParam(path string) bool {
@@ -259,6 +444,28 @@ func isParam(path string) bool {
 	return string(path[0]) == ":"
 }
 
+// hasConstraint determines whether the path of a parameter segment restricts
+// the values it matches.
+func hasConstraint(path string) bool {
+	_, constraint := splitParam(path)
+	return constraint != ""
+}
+
+func isWildcard(path string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	return string(path[0]) == "*"
+}
+
+// trimSlashes removes a single leading and trailing forward-slash from a path,
+// in the same way as splitPath.
+func trimSlashes(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	return strings.TrimSuffix(path, "/")
+}
+
 func splitPath(path string) (head string, tail string) {
 	if len(path) == 0 {
 		return
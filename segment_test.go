@@ -3,6 +3,7 @@ package nile
 import (
 	"log"
 	"net/http"
+	"reflect"
 	"testing"
 )
 
@@ -132,6 +133,17 @@ func TestSegmentMatching(t *testing.T) {
 		{"/products/:id", "GET", "/products/1", true, map[string]string{"id": "1"}},
 		{"/products", "GET", "/product", false, map[string]string{}},
 		{"/products/:id/edit", "PATCH", "/products/4/edit", true, map[string]string{"id": "4"}},
+		{"/files/*path", "GET", "/files/a/b/c.txt", true, map[string]string{"path": "a/b/c.txt"}},
+		{"/files/*path", "GET", "/files/a/", true, map[string]string{"path": "a"}},
+		{"/users/:id/files/*path", "GET", "/users/7/files/a/b", true, map[string]string{"id": "7", "path": "a/b"}},
+		{"/*path", "GET", "/a/b", true, map[string]string{"path": "a/b"}},
+		{"/files/*path", "GET", "/other/a", false, map[string]string{}},
+		{"/products/:id<int>", "GET", "/products/12", true, map[string]string{"id": "12"}},
+		{"/products/:id<int>", "GET", "/products/banana", false, map[string]string{}},
+		{"/products/:slug<[a-z-]+>", "GET", "/products/red-shoes", true, map[string]string{"slug": "red-shoes"}},
+		{"/products/:slug<[a-z-]+>", "GET", "/products/Red", false, map[string]string{}},
+		{"/items/:uuid<uuid>/edit", "GET", "/items/6ba7b810-9dad-11d1-80b4-00c04fd430c8/edit", true, map[string]string{"uuid": "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}},
+		{"/items/:uuid<uuid>/edit", "GET", "/items/6ba7b810/edit", false, map[string]string{}},
 	}
 
 	for _, test := range tests {
@@ -164,3 +176,214 @@ func TestSegmentMatching(t *testing.T) {
 		}
 	}
 }
+
+func TestWildcardSegmentPriority(t *testing.T) {
+	var tests = []struct {
+		path        string
+		wantHandler string
+		wantParams  map[string]string
+	}{
+		{"/files/new", "static", map[string]string{}},
+		{"/files/1", "param", map[string]string{"id": "1"}},
+		{"/files/1/edit", "wildcard", map[string]string{"path": "1/edit"}},
+	}
+
+	var routes = []struct {
+		path        string
+		handlerName string
+	}{
+		{"/files/*path", "wildcard"},
+		{"/files/:id", "param"},
+		{"/files/new", "static"},
+	}
+
+	root := newSegment("files")
+	for _, route := range routes {
+		seg, err := newSegmentEndpoint(route.path, http.MethodGet, namedHandler(route.handlerName))
+		if err != nil {
+			t.Fatalf("newSegmentEndpoint(%s), want <nil> err, got %v err", route.path, err)
+		}
+
+		if root, err = mergeSegments(root, seg); err != nil {
+			t.Fatalf("mergeSegments(%s), want <nil> err, got %v err", route.path, err)
+		}
+	}
+
+	tree := newSegment("")
+	if err := tree.AddChild(root); err != nil {
+		t.Fatalf("Segment.AddChild(files), want <nil> err, got %v err", err)
+	}
+
+	routesTree := compileTree(tree, "", nil)
+	for _, test := range tests {
+		gotHandler, gotParams, found := lookupHandler(routesTree, test.path)
+		if !found {
+			t.Errorf("node.lookup(%s), want match, got <nil>", test.path)
+			continue
+		}
+
+		if gotHandler != test.wantHandler {
+			t.Errorf("node.lookup(%s) handler, want %s, got %s", test.path, test.wantHandler, gotHandler)
+		}
+
+		if !reflect.DeepEqual(gotParams, test.wantParams) {
+			t.Errorf("node.lookup(%s) params, want %v, got %v", test.path, test.wantParams, gotParams)
+		}
+	}
+}
+
+func TestWildcardSegmentMustBeLast(t *testing.T) {
+	_, err := newSegmentEndpoint("/files/*path/edit", http.MethodGet, namedHandler("wildcard"))
+	want := "Wildcard segment *path must be the last segment in the path"
+	if err == nil || err.Error() != want {
+		t.Errorf("newSegmentEndpoint(/files/*path/edit) error, want %s, got %v", want, err)
+	}
+}
+
+// namedHandler creates a HandlerFunc whose response body is its name, so that
+// tests can identify which handler was matched.
+func namedHandler(name string) HandlerFunc {
+	return func(Context) Response {
+		return NewGenericResponse(http.StatusOK, name)
+	}
+}
+
+func TestConstrainedSegmentFallthrough(t *testing.T) {
+	constrained, err := newSegmentEndpoint("/products/:id<int>", http.MethodGet, namedHandler("param"))
+	if err != nil {
+		t.Fatalf("newSegmentEndpoint(/products/:id<int>), want <nil> err, got %v err", err)
+	}
+
+	wildcard, err := newSegmentEndpoint("/products/*rest", http.MethodGet, namedHandler("wildcard"))
+	if err != nil {
+		t.Fatalf("newSegmentEndpoint(/products/*rest), want <nil> err, got %v err", err)
+	}
+
+	merged, err := mergeSegments(constrained, wildcard)
+	if err != nil {
+		t.Fatalf("mergeSegments(), want <nil> err, got %v err", err)
+	}
+
+	var tests = []struct {
+		path        string
+		wantHandler string
+	}{
+		{"/products/12", "param"},
+		{"/products/banana", "wildcard"},
+	}
+
+	tree := newSegment("")
+	if err := tree.AddChild(merged); err != nil {
+		t.Fatalf("Segment.AddChild(products), want <nil> err, got %v err", err)
+	}
+
+	routesTree := compileTree(tree, "", nil)
+	for _, test := range tests {
+		gotHandler, _, found := lookupHandler(routesTree, test.path)
+		if !found {
+			t.Errorf("node.lookup(%s), want match, got <nil>", test.path)
+			continue
+		}
+
+		if gotHandler != test.wantHandler {
+			t.Errorf("node.lookup(%s) handler, want %s, got %s", test.path, test.wantHandler, gotHandler)
+		}
+	}
+}
+
+func TestInvalidSegmentConstraint(t *testing.T) {
+	_, err := newSegmentEndpoint("/products/:id<[a-z>", http.MethodGet, namedHandler("param"))
+	if err == nil {
+		t.Error("newSegmentEndpoint(/products/:id<[a-z>) error, want error, got <nil>")
+	}
+}
+
+func TestSegmentMatchPriority(t *testing.T) {
+	var routes = []struct {
+		path        string
+		handlerName string
+	}{
+		{"/*all", "root-wildcard"},
+		{"/:page", "root-param"},
+		{"/about", "root-static"},
+		{"/a/*rest", "a-wildcard"},
+		{"/a/:x/c", "a-param-c"},
+		{"/a/:n<int>/c", "a-int-c"},
+		{"/a/b/d", "a-static-d"},
+		{"/a/:x", "a-param"},
+		{"/a/b", "a-static"},
+	}
+
+	var tests = []struct {
+		path        string
+		wantHandler string
+		wantParams  map[string]string
+	}{
+		{"/about", "root-static", map[string]string{}},
+		{"/contact", "root-param", map[string]string{"page": "contact"}},
+		{"/contact/us", "root-wildcard", map[string]string{"all": "contact/us"}},
+		{"/a/b", "a-static", map[string]string{}},
+		{"/a/z", "a-param", map[string]string{"x": "z"}},
+		{"/a/b/d", "a-static-d", map[string]string{}},
+		{"/a/b/c", "a-param-c", map[string]string{"x": "b"}},
+		{"/a/7/c", "a-int-c", map[string]string{"n": "7"}},
+		{"/a/b/e", "a-wildcard", map[string]string{"rest": "b/e"}},
+		{"/a", "root-param", map[string]string{"page": "a"}},
+	}
+
+	// Register the routes in several orders to ensure that the priority
+	// doesn't depend on the order of registration.
+	for shift := range routes {
+		root := newSegment("")
+		for idx := range routes {
+			route := routes[(idx+shift)%len(routes)]
+			seg, err := newSegmentEndpoint(route.path, http.MethodGet, namedHandler(route.handlerName))
+			if err != nil {
+				t.Fatalf("newSegmentEndpoint(%s), want <nil> err, got %v err", route.path, err)
+			}
+
+			if err := root.AddChild(seg); err != nil {
+				t.Fatalf("Segment.AddChild(%s), want <nil> err, got %v err", route.path, err)
+			}
+		}
+
+		routesTree := compileTree(root, "", nil)
+		for _, test := range tests {
+			gotHandler, gotParams, found := lookupHandler(routesTree, test.path)
+			if !found {
+				t.Errorf("Shift %d: node.lookup(%s), want match, got <nil>", shift, test.path)
+				continue
+			}
+
+			if gotHandler != test.wantHandler {
+				t.Errorf("Shift %d: node.lookup(%s) handler, want %s, got %s", shift, test.path, test.wantHandler, gotHandler)
+			}
+
+			if !reflect.DeepEqual(gotParams, test.wantParams) {
+				t.Errorf("Shift %d: node.lookup(%s) params, want %v, got %v", shift, test.path, test.wantParams, gotParams)
+			}
+		}
+	}
+}
+
+// lookupHandler matches a path against a compiled radix tree, in the same way
+// as a Router, and gets the name of the GET handler of the route it matched,
+// made with namedHandler, and the values of its parameters.
+func lookupHandler(tree *node, path string) (string, map[string]string, bool) {
+	found, params := tree.lookup(path, nil)
+	if found == nil {
+		return "", nil, false
+	}
+
+	endPt, exists := found.segment.Endpoint(http.MethodGet)
+	if !exists {
+		return "", nil, false
+	}
+
+	values := map[string]string{}
+	for _, p := range params {
+		values[p.name] = p.value
+	}
+
+	return endPt.Handler()(nil).Body().(string), values, true
+}
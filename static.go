@@ -0,0 +1,252 @@
+package nile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticOption configures how Router.Static serves files.
+type StaticOption func(cfg *staticConfig)
+
+// staticConfig is the configuration of a file server that is built from its
+// StaticOptions.
+type staticConfig struct {
+	index  []string
+	spa    bool
+	browse bool
+}
+
+// StaticIndex sets the names of the files that are served for a directory, in
+// order of preference. The default is index.html.
+func StaticIndex(names ...string) StaticOption {
+	return func(cfg *staticConfig) {
+		cfg.index = names
+	}
+}
+
+// StaticSPA serves the index file of the root directory for any path that
+// doesn't exist, so that a single-page application can handle its own routes.
+func StaticSPA() StaticOption {
+	return func(cfg *staticConfig) {
+		cfg.spa = true
+	}
+}
+
+// StaticBrowse responds to a request for a directory without an index file
+// with a JSON list of its entries. Directories can't be listed by default.
+func StaticBrowse() StaticOption {
+	return func(cfg *staticConfig) {
+		cfg.browse = true
+	}
+}
+
+// DirEntry describes a file in a directory listing served by Router.Static.
+type DirEntry struct {
+	Name     string    `json:"name"`
+	Dir      bool      `json:"dir"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+func (r *router) Static(prefix string, fsys fs.FS, opts ...StaticOption) error {
+	cfg := &staticConfig{index: []string{"index.html"}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	server := &fileServer{router: r.root(), fsys: fsys, cfg: cfg}
+	return r.mount(prefix, server, []string{http.MethodGet, http.MethodHead})
+}
+
+// fileServer serves the files of an fs.FS. Errors are written as an
+// ErrorResponse, like any other response of a Router.
+type fileServer struct {
+	router *router
+	fsys   fs.FS
+	cfg    *staticConfig
+	// etags caches the ETags of files without a modification time, such as
+	// those of an embed.FS, since they are computed from the file's contents.
+	etags sync.Map
+}
+
+func (s *fileServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+req.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+
+	err := s.serve(w, req, name)
+	if errors.Is(err, fs.ErrNotExist) && s.cfg.spa && name != "." {
+		err = s.serve(w, req, ".")
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid), errors.Is(err, fs.ErrPermission):
+		s.router.writeResponse(NewResourceNotFound(), w)
+	default:
+		s.router.writeResponse(NewInternalServiceError(err), w)
+	}
+}
+
+// serve writes a file, or the index file or listing of a directory. It returns
+// an error wrapping fs.ErrNotExist if there is nothing to serve.
+func (s *fileServer) serve(w http.ResponseWriter, req *http.Request, name string) error {
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return s.serveFile(w, req, name)
+	}
+
+	for _, index := range s.cfg.index {
+		err := s.serveFile(w, req, path.Join(name, index))
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	if !s.cfg.browse {
+		return fmt.Errorf("Unable to serve directory %s: %w", name, fs.ErrNotExist)
+	}
+
+	return s.serveListing(w, name)
+}
+
+// serveFile writes a regular file. A gzipped copy of the file, with a .gz
+// extension, is served instead if it exists and the client accepts it.
+// Conditional and Range requests are handled by http.ServeContent.
+func (s *fileServer) serveFile(w http.ResponseWriter, req *http.Request, name string) error {
+	served := name
+	if acceptsGzip(req.Header.Get("Accept-Encoding")) {
+		if info, err := fs.Stat(s.fsys, name+".gz"); err == nil && !info.IsDir() {
+			served = name + ".gz"
+		}
+	}
+
+	file, err := s.fsys.Open(served)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("Unable to serve file %s: %w", name, fs.ErrNotExist)
+	}
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+
+		content = bytes.NewReader(data)
+	}
+
+	etag, err := s.etag(served, info, content)
+	if err != nil {
+		return err
+	}
+
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Add("Vary", "Accept-Encoding")
+	if served != name {
+		header.Set("Content-Encoding", "gzip")
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+	}
+
+	http.ServeContent(w, req, path.Base(name), info.ModTime(), content)
+	return nil
+}
+
+// etag gets the ETag of a file. It is based on the modification time and
+// size of the file if it has a modification time, and on a hash of its
+// contents otherwise.
+func (s *fileServer) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()), nil
+	}
+
+	if etag, found := s.etags.Load(name); found {
+		return etag.(string), nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+// serveListing writes the entries of a directory as JSON, sorted by name.
+func (s *fileServer) serveListing(w http.ResponseWriter, name string) error {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		return err
+	}
+
+	listing := make([]DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		listing = append(listing, DirEntry{
+			Name:     entry.Name(),
+			Dir:      entry.IsDir(),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+
+	sort.Slice(listing, func(i, j int) bool {
+		return listing[i].Name < listing[j].Name
+	})
+
+	s.router.writeResponse(NewGenericResponse(http.StatusOK, listing), w)
+	return nil
+}
+
+// acceptsGzip checks whether an Accept-Encoding header allows a gzipped
+// response.
+func acceptsGzip(header string) bool {
+	for _, encoding := range strings.Split(header, ",") {
+		encoding, params, err := mime.ParseMediaType(strings.TrimSpace(encoding))
+		if err == nil && encoding == "gzip" && params["q"] != "0" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+package nile
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRouterStatic(t *testing.T) {
+	modified := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":        {Data: []byte("<h1>home</h1>")},
+		"app.js":            {Data: []byte("console.log('app')")},
+		"app.js.gz":         {Data: []byte("gzipped")},
+		"docs/index.htm":    {Data: []byte("docs")},
+		"assets/logo.svg":   {Data: []byte("<svg/>"), ModTime: modified},
+		"assets/styles.css": {Data: []byte("body{}")},
+	}
+
+	r := New()
+	if err := r.Static("/ui", fsys); err != nil {
+		t.Fatalf("Router.Static(/ui) error, want <nil>, got %v", err)
+	}
+	if err := r.Static("/spa", fsys, StaticSPA(), StaticIndex("index.htm", "index.html")); err != nil {
+		t.Fatalf("Router.Static(/spa) error, want <nil>, got %v", err)
+	}
+	if err := r.Static("/files", fsys, StaticBrowse()); err != nil {
+		t.Fatalf("Router.Static(/files) error, want <nil>, got %v", err)
+	}
+
+	var tests = []struct {
+		method     string
+		path       string
+		header     string
+		value      string
+		wantStatus int
+		wantBody   string
+	}{
+		{http.MethodGet, "/ui", "", "", http.StatusOK, "<h1>home</h1>"},
+		{http.MethodGet, "/ui/", "", "", http.StatusOK, "<h1>home</h1>"},
+		{http.MethodGet, "/ui/app.js", "", "", http.StatusOK, "console.log('app')"},
+		{http.MethodGet, "/ui/app.js", "Accept-Encoding", "gzip, br", http.StatusOK, "gzipped"},
+		{http.MethodGet, "/ui/app.js", "Accept-Encoding", "gzip;q=0", http.StatusOK, "console.log('app')"},
+		{http.MethodGet, "/ui/assets/styles.css", "Range", "bytes=0-3", http.StatusPartialContent, "body"},
+		{http.MethodGet, "/ui/assets/logo.svg", "If-Modified-Since", modified.Format(http.TimeFormat), http.StatusNotModified, ""},
+		{http.MethodHead, "/ui/assets/logo.svg", "", "", http.StatusOK, ""},
+		{http.MethodGet, "/ui/assets", "", "", http.StatusNotFound, `"code":"00002"`},
+		{http.MethodGet, "/ui/missing.js", "", "", http.StatusNotFound, `"code":"00002"`},
+		{http.MethodGet, "/ui/../static.go", "", "", http.StatusNotFound, ""},
+		{http.MethodPost, "/ui/app.js", "", "", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/spa/docs", "", "", http.StatusOK, "docs"},
+		{http.MethodGet, "/spa/products/1", "", "", http.StatusOK, "<h1>home</h1>"},
+		{http.MethodGet, "/files/assets", "", "", http.StatusOK, `"name":"logo.svg"`},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/", nil)
+		req.URL.Path = test.path
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != test.wantStatus {
+			t.Errorf("%s %s with %s: %s status, want %d, got %d", test.method, test.path, test.header, test.value, test.wantStatus, w.Code)
+			continue
+		}
+		if !strings.Contains(w.Body.String(), test.wantBody) {
+			t.Errorf("%s %s with %s: %s body, want to contain %s, got %s", test.method, test.path, test.header, test.value, test.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestRouterStaticHeaders(t *testing.T) {
+	r := New()
+	r.Static("/", fstest.MapFS{
+		"app.js":    {Data: []byte("console.log('app')")},
+		"app.js.gz": {Data: []byte("gzipped")},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var headers = []struct {
+		name string
+		want string
+	}{
+		{"Content-Encoding", "gzip"},
+		{"Content-Type", "text/javascript; charset=utf-8"},
+		{"Vary", "Accept-Encoding"},
+	}
+
+	for _, header := range headers {
+		if got := w.Header().Get(header.name); got != header.want {
+			t.Errorf("GET /app.js %s header, want %s, got %s", header.name, header.want, got)
+		}
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GET /app.js ETag header, want ETag, got none")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("GET /app.js with If-None-Match status, want %d, got %d", http.StatusNotModified, w.Code)
+	}
+}
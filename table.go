@@ -0,0 +1,326 @@
+package nile
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// routeTable is a snapshot of the routes of a Router. A routeTable is never
+// modified once a Router starts using it. Instead, changes are made to a copy
+// that then replaces it, so that requests can be served while routes change.
+type routeTable struct {
+	// tree is the root of the Segment tree of the routes that match any host.
+	// Its children are the first segments of each route's path.
+	tree *segment
+	// routes is the radix tree compiled from the Segment tree and Middleware,
+	// which requests are matched against. The routeTable is compiled when it
+	// first serves a request, rather than each time a route changes.
+	routes *node
+	// compiled is set to 1, atomically, once the radix trees are compiled.
+	compiled uint32
+	// notFound, methodNotAllowed, notAcceptable and options respond to
+	// requests that don't match an endpoint. Like the handlers of routes, they
+	// are wrapped by the Middleware of the root Router when the routeTable is
+	// compiled.
+	notFound         HandlerFunc
+	methodNotAllowed HandlerFunc
+	notAcceptable    HandlerFunc
+	options          HandlerFunc
+	// hosts holds the routes that only match a host pattern, from the most to
+	// the least specific pattern, and otherwise in the order that each pattern
+	// was first used. They are checked before the routes that match any host.
+	hosts []*hostRoutes
+	// names holds the templates of the named routes.
+	names map[string]*routeURL
+	// added holds the routes added by the change being made to a draft, so
+	// that a strict Router only needs to check those for conflicts.
+	added []addedRoute
+}
+
+// addedRoute is the host pattern and path template of a route added to a
+// routeTable.
+type addedRoute struct {
+	host string
+	path string
+}
+
+// hostRoutes are the routes of a routeTable that only match a host pattern.
+type hostRoutes struct {
+	host   *hostPattern
+	tree   *segment
+	routes *node
+}
+
+// newRouteTable creates a routeTable without any routes.
+func newRouteTable() *routeTable {
+	return &routeTable{
+		tree:  newSegment(""),
+		names: map[string]*routeURL{},
+	}
+}
+
+// clone creates a copy of the routeTable that can be modified without
+// affecting the original.
+func (t *routeTable) clone() *routeTable {
+	names := make(map[string]*routeURL, len(t.names))
+	for name, template := range t.names {
+		names[name] = template
+	}
+
+	hosts := make([]*hostRoutes, len(t.hosts))
+	for idx, routes := range t.hosts {
+		hosts[idx] = &hostRoutes{
+			host: routes.host,
+			tree: routes.tree.clone(),
+		}
+	}
+
+	return &routeTable{
+		tree:  t.tree.clone(),
+		hosts: hosts,
+		names: names,
+	}
+}
+
+// segments gets the Segment tree for the routes of a host pattern, or for the
+// routes that match any host if the pattern is empty. A tree is created for a
+// new host pattern when create is true.
+func (t *routeTable) segments(host string, create bool) (*segment, error) {
+	if host == "" {
+		return t.tree, nil
+	}
+
+	for _, routes := range t.hosts {
+		if routes.host.pattern == host {
+			return routes.tree, nil
+		}
+	}
+
+	if !create {
+		return nil, nil
+	}
+
+	pattern, err := newHostPattern(host)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := &hostRoutes{
+		host: pattern,
+		tree: newSegment(""),
+	}
+
+	idx := len(t.hosts)
+	for existing, other := range t.hosts {
+		if pattern.before(other.host) {
+			idx = existing
+			break
+		}
+	}
+
+	t.hosts = append(t.hosts, nil)
+	copy(t.hosts[idx+1:], t.hosts[idx:])
+	t.hosts[idx] = routes
+	return routes.tree, nil
+}
+
+// compile rebuilds the radix trees that requests are matched against, and
+// wraps the handlers of the root Router for requests that don't match an
+// endpoint with its Middleware.
+func (t *routeTable) compile(root *router) {
+	t.routes = compileTree(t.tree, "", root.middleware)
+	for _, routes := range t.hosts {
+		routes.routes = compileTree(routes.tree, routes.host.pattern, root.middleware)
+	}
+
+	t.notFound = chain(root.notFound, root.middleware)
+	t.methodNotAllowed = chain(root.methodNotAllowed, root.middleware)
+	t.notAcceptable = chain(notAcceptable, root.middleware)
+	t.options = chain(options, root.middleware)
+}
+
+// lookup finds the leaf of the route that matches the Host and path of a
+// request, appending the values of any host and path parameters to params.
+func (t *routeTable) lookup(host string, path string, params []param) (*leaf, []param) {
+	for _, routes := range t.hosts {
+		hostParams, matches := routes.host.match(host, params)
+		if !matches {
+			continue
+		}
+
+		if found, all := routes.routes.lookup(path, hostParams); found != nil {
+			return found, all
+		}
+	}
+
+	return t.routes.lookup(path, params)
+}
+
+// add registers a route in the routeTable. The route only matches requests
+// for the host pattern, unless it's empty.
+func (t *routeTable) add(host string, path string, method string, handler HandlerFunc, opts []RouteOption) error {
+	name := newRouteConfig(opts).name
+	if _, exists := t.names[name]; exists && name != "" {
+		return fmt.Errorf("Unable to add route %s: the name %s is already in use", path, name)
+	}
+
+	seg, err := newSegmentEndpoint(path, method, handler, opts...)
+	if err != nil {
+		return err
+	}
+
+	tree, err := t.segments(host, true)
+	if err != nil {
+		return err
+	}
+
+	template := newRouteURL(path, seg)
+	for _, routes := range t.hosts {
+		if routes.host.pattern == host {
+			template.host = routes.host
+		}
+	}
+
+	if err := tree.AddChild(seg); err != nil {
+		return err
+	}
+
+	if name != "" {
+		t.names[name] = template
+	}
+
+	t.added = append(t.added, addedRoute{host: host, path: path})
+	return nil
+}
+
+// remove deletes the endpoints of a route for a host pattern from the
+// routeTable. Only the endpoints for which fn returns true are deleted.
+func (t *routeTable) remove(host string, method string, path string, fn func(endPt endpoint) bool) error {
+	tree, err := t.segments(host, false)
+	if err != nil {
+		return err
+	}
+
+	var removed []endpoint
+	if tree != nil {
+		removed = tree.RemoveEndpoint(path, method, fn)
+	}
+
+	if len(removed) == 0 {
+		return fmt.Errorf("Unable to remove %s endpoint %s: route does not exist", method, path)
+	}
+
+	for _, endPt := range removed {
+		if name := endPt.Name(); name != "" {
+			delete(t.names, name)
+		}
+	}
+
+	return nil
+}
+
+// update changes the routes of a Router. Changes are made to a draft, a copy
+// of the current routeTable that isn't used until it's published, which
+// happens the next time the routes are read. The changes made between two
+// reads, such as registering the routes of a service when it starts, are
+// therefore made to the same draft, without copying or compiling the routes
+// for each of them.
+//
+// A strict Router only checks the routes that fn adds for conflicts, since
+// the routes that were already registered have none.
+//
+// If fn fails, the draft is rebuilt from the current routeTable and the
+// changes that succeeded, so a failed change has no effect. Updates are made
+// one at a time, and requests that are being served continue to use the
+// routeTable that they started with.
+func (r *router) update(fn func(t *routeTable) error) error {
+	root := r.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	draft := root.edit()
+	draft.added = nil
+	err := fn(draft)
+	if err == nil && root.strict {
+		for _, route := range draft.added {
+			if conflicts := draft.validateRoute(root.pathPolicy, route.host, route.path); len(conflicts) > 0 {
+				err = conflicts[0]
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		root.draft = nil
+		root.edit()
+		for _, change := range root.changes {
+			change(root.draft)
+		}
+
+		return err
+	}
+
+	root.changes = append(root.changes, fn)
+	return nil
+}
+
+// edit gets the draft of the routeTable, creating it if there isn't one. It
+// must only be called on the root router, while holding its lock.
+func (r *router) edit() *routeTable {
+	if r.draft == nil {
+		r.draft = r.table.Load().(*routeTable).clone()
+		atomic.StoreUint32(&r.drafted, 1)
+	}
+
+	return r.draft
+}
+
+// publish replaces the routeTable with its draft, if there is one. It must
+// only be called on the root router, while holding its lock.
+func (r *router) publish() {
+	if r.draft == nil {
+		return
+	}
+
+	r.table.Store(r.draft)
+	r.draft, r.changes = nil, nil
+	atomic.StoreUint32(&r.drafted, 0)
+}
+
+// currentTable gets the routeTable that requests are currently served with,
+// publishing the draft first if there is one. Its radix trees may not have
+// been compiled yet.
+func (r *router) currentTable() *routeTable {
+	root := r.root()
+	if atomic.LoadUint32(&root.drafted) == 1 {
+		root.mu.Lock()
+		root.publish()
+		root.mu.Unlock()
+	}
+
+	return root.table.Load().(*routeTable)
+}
+
+// compiledTable gets the routeTable that requests are currently served with,
+// compiling its radix trees first if this is the first request it serves. The
+// routeTable is compiled while holding the root router's lock, so that the
+// Middleware of groups doesn't change while it's compiled.
+func (r *router) compiledTable() *routeTable {
+	root := r.root()
+	table := root.currentTable()
+	if atomic.LoadUint32(&table.compiled) == 1 {
+		return table
+	}
+
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	root.publish()
+	table = root.table.Load().(*routeTable)
+	if atomic.LoadUint32(&table.compiled) == 0 {
+		table.compile(root)
+		atomic.StoreUint32(&table.compiled, 1)
+	}
+
+	return table
+}
@@ -0,0 +1,229 @@
+package nile
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestRouterRemove(t *testing.T) {
+	r := New()
+	r.GET("/products", namedHandler("list"))
+	r.GET("/products/:id", namedHandler("show"), Name("product"))
+	r.DELETE("/products/:id", namedHandler("delete"))
+	r.GET("/products/:id/edit", namedHandler("edit"))
+
+	var steps = []struct {
+		method     string
+		path       string
+		wantErr    bool
+		wantRoutes int
+	}{
+		{http.MethodGet, "/products/:id", false, 3},
+		{http.MethodGet, "/products/:id", true, 3},
+		{http.MethodGet, "/products/:slug", true, 3},
+		{http.MethodDelete, "/products/:id", false, 2},
+		{http.MethodGet, "/products/:id/edit", false, 1},
+		{http.MethodGet, "/products", false, 0},
+	}
+
+	for _, step := range steps {
+		err := r.Remove(step.method, step.path)
+		if (err != nil) != step.wantErr {
+			t.Errorf("Router.Remove(%s, %s) error, want error %v, got %v", step.method, step.path, step.wantErr, err)
+		}
+
+		if got := len(r.Routes()); got != step.wantRoutes {
+			t.Errorf("len(Router.Routes()) after Remove(%s, %s), want %d, got %d", step.method, step.path, step.wantRoutes, got)
+		}
+	}
+
+	if _, err := r.URL("product", map[string]string{"id": "1"}, nil); err == nil {
+		t.Error("Router.URL(product) after removal, want error, got <nil>")
+	}
+
+	if got := r.(*router).currentTable().tree.Children(); len(got) != 0 {
+		t.Errorf("Segment.Children() after removing every route, want none, got %d", len(got))
+	}
+
+	w := httptest.NewRecorder()
+	r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /products after removal status, want %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestRouterReplace(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	api.GET("/products/:id", namedHandler("old"), Name("product"))
+
+	if err := api.Replace(http.MethodGet, "/products/:id", namedHandler("new"), Name("product")); err != nil {
+		t.Fatalf("Router.Replace(GET, /products/:id) error, want <nil>, got %v", err)
+	}
+	if err := api.Replace(http.MethodPost, "/products", namedHandler("create")); err != nil {
+		t.Fatalf("Router.Replace(POST, /products) error, want <nil>, got %v", err)
+	}
+	if err := api.Replace("FETCH", "/products", namedHandler("fetch")); err == nil {
+		t.Error("Router.Replace(FETCH, /products) error, want error, got <nil>")
+	}
+
+	var tests = []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "/api/products/1", `"new"`},
+		{http.MethodPost, "/api/products", `"create"`},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r.(http.Handler).ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Body.String() != test.want {
+			t.Errorf("%s %s body, want %s, got %s", test.method, test.path, test.want, w.Body.String())
+		}
+	}
+
+	if got := len(r.Routes()); got != 2 {
+		t.Errorf("len(Router.Routes()), want 2, got %d", got)
+	}
+}
+
+func TestRouterConcurrentChanges(t *testing.T) {
+	r := New()
+	r.GET("/stable/:id", namedHandler("stable"))
+
+	const workers = 4
+	const iterations = 200
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				w := httptest.NewRecorder()
+				r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stable/1", nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("GET /stable/1 status, want %d, got %d", http.StatusOK, w.Code)
+					return
+				}
+
+				w = httptest.NewRecorder()
+				r.(http.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dynamic/1", nil))
+				r.Routes()
+				r.URL("dynamic-0", map[string]string{"id": "1"}, nil)
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for worker := 0; worker < workers; worker++ {
+		writers.Add(1)
+		go func(worker int) {
+			defer writers.Done()
+
+			group := r.Group(fmt.Sprintf("/dynamic/%d", worker))
+			for i := 0; i < iterations; i++ {
+				path := fmt.Sprintf("/routes/%d", i)
+				if err := group.GET(path, namedHandler("dynamic"), Name(fmt.Sprintf("dynamic-%d-%d", worker, i))); err != nil {
+					t.Errorf("Router.GET(%s) error, want <nil>, got %v", path, err)
+				}
+				if err := group.Replace(http.MethodGet, path, namedHandler("replaced")); err != nil {
+					t.Errorf("Router.Replace(GET, %s) error, want <nil>, got %v", path, err)
+				}
+				if i%10 == 0 {
+					group.Use(func(next HandlerFunc) HandlerFunc { return next })
+				}
+				if err := group.Remove(http.MethodGet, path); err != nil {
+					t.Errorf("Router.Remove(GET, %s) error, want <nil>, got %v", path, err)
+				}
+			}
+		}(worker)
+	}
+
+	writers.Wait()
+	close(done)
+	wg.Wait()
+
+	if got := len(r.Routes()); got != 1 {
+		t.Errorf("len(Router.Routes()) after concurrent changes, want 1, got %d", got)
+	}
+}
+
+func TestRouterFailedChangeIsDiscarded(t *testing.T) {
+	r := New(WithStrictRoutes())
+	r.GET("/products", namedHandler("list"))
+	r.GET("/products/:id", namedHandler("show"), Name("product"))
+
+	if err := r.Replace(http.MethodGet, "/products/:sku", namedHandler("duplicate"), Name("sku")); err == nil {
+		t.Error("Router.Replace(GET, /products/:sku) error, want error, got <nil>")
+	}
+	if err := r.GET("/files/*path/edit", namedHandler("invalid")); err == nil {
+		t.Error("Router.GET(/files/*path/edit) error, want error, got <nil>")
+	}
+	if err := r.Replace(http.MethodGet, "/products/:id", namedHandler("replaced"), Name("product"), Describe("Shows a product")); err != nil {
+		t.Errorf("Router.Replace(GET, /products/:id) error, want <nil>, got %v", err)
+	}
+
+	var paths []string
+	for _, route := range r.Routes() {
+		paths = append(paths, route.Path)
+	}
+
+	if want := "[/products /products/:id]"; fmt.Sprint(paths) != want {
+		t.Errorf("Router.Routes() paths, want %s, got %v", want, paths)
+	}
+	if _, err := r.URL("sku", map[string]string{"sku": "1"}, nil); err == nil {
+		t.Error("Router.URL(sku), want error, got <nil>")
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+	if w.Body.String() != `"replaced"` {
+		t.Errorf("GET /products/1 body, want %s, got %s", `"replaced"`, w.Body.String())
+	}
+}
+
+func BenchmarkRouterRegister(b *testing.B) {
+	for _, strict := range []bool{false, true} {
+		for _, count := range []int{1000, 4000} {
+			paths := make([]string, count)
+			for idx := range paths {
+				paths[idx] = fmt.Sprintf("/api/r%d/:id/x%d", idx, idx)
+			}
+
+			var opts []RouterOption
+			if strict {
+				opts = append(opts, WithStrictRoutes())
+			}
+
+			b.Run(fmt.Sprintf("strict=%v/routes=%d", strict, count), func(b *testing.B) {
+				req := httptest.NewRequest(http.MethodGet, "/api/r0/1/x0", nil)
+
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					r := New(opts...)
+					for _, path := range paths {
+						if err := r.GET(path, namedHandler(path)); err != nil {
+							b.Fatalf("Router.GET(%s) error, want <nil>, got %v", path, err)
+						}
+					}
+
+					r.ServeHTTP(httptest.NewRecorder(), req)
+				}
+			})
+		}
+	}
+}
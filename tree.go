@@ -0,0 +1,277 @@
+package nile
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// node is a node of the compressed radix tree that a Router compiles its
+// Segment tree into. Static parts of paths are stored as shared prefixes that
+// may span several segments, while parameters and wildcards are stored as
+// nodes of their own. Lookups walk the tree without splitting the path, so
+// they don't allocate.
+type node struct {
+	// prefix is the static part of the path that the node matches. It is empty
+	// for parameter and wildcard nodes.
+	prefix string
+
+	// indices holds the first byte of the prefix of each static child.
+	indices  string
+	children []*node
+
+	// params are the parameter children, with constrained parameters first and
+	// otherwise in the order that they were added.
+	params   []*node
+	wildcard *node
+
+	// key is the path of the Segment that a parameter or wildcard node was
+	// compiled from, such as :id<int>.
+	key        string
+	paramName  string
+	constraint *regexp.Regexp
+
+	// leaf is set when a route ends at the node.
+	leaf *leaf
+}
+
+// leaf holds the compiled endpoints of a route.
+type leaf struct {
+	segment *segment
+	// handlers are the Handlers of the endpoints for each HTTP method, wrapped
+	// by all of the Middleware that applies to the route.
+	handlers map[string]*variants
+	// allow is the value of the Allow header for the route.
+	allow string
+}
+
+// compileTree converts a Segment tree into a radix tree. The children of the
+// root Segment are the first segments of each route, and the Middleware is
+// wrapped around the handler of every endpoint. The host pattern of the
+// routes is only used to describe them.
+func compileTree(root *segment, host string, middleware []Middleware) *node {
+	tree := &node{}
+	for _, child := range root.Children() {
+		tree.compile(child, "", routeTemplate{host: host}, middleware)
+	}
+
+	return tree
+}
+
+// routeTemplate is the host pattern, path template and parameter names of the
+// Segments leading up to the one being compiled.
+type routeTemplate struct {
+	host   string
+	path   string
+	params []string
+}
+
+// compile adds a Segment and its descendants below the node. The static part
+// of the path that precedes the Segment, but hasn't been added to the tree yet,
+// is passed as static.
+func (n *node) compile(seg *segment, static string, template routeTemplate, middleware []Middleware) {
+	template.path += "/" + seg.Path
+	if isParam(seg.Path) || isWildcard(seg.Path) {
+		template.params = append(template.params[:len(template.params):len(template.params)], seg.ParamName())
+	}
+
+	switch {
+	case isWildcard(seg.Path):
+		n = n.insertStatic(static + "/").wildcardChild(seg)
+		static = ""
+	case isParam(seg.Path):
+		n = n.insertStatic(static + "/").paramChild(seg)
+		static = ""
+	default:
+		// Percent signs are encoded in the paths that are matched.
+		static += "/" + strings.ReplaceAll(seg.Path, "%", "%25")
+	}
+
+	if len(seg.endpoints) > 0 {
+		n.insertStatic(static).leaf = newLeaf(seg, template, middleware)
+	}
+
+	for _, child := range seg.Children() {
+		n.compile(child, static, template, middleware)
+	}
+}
+
+// insertStatic gets the node that matches a static path below the current
+// node, splitting existing nodes or creating new ones as needed.
+func (n *node) insertStatic(path string) *node {
+	if path == "" {
+		return n
+	}
+
+	idx := strings.IndexByte(n.indices, path[0])
+	if idx < 0 {
+		child := &node{prefix: path}
+		n.indices += path[:1]
+		n.children = append(n.children, child)
+		return child
+	}
+
+	child := n.children[idx]
+	common := commonPrefix(path, child.prefix)
+	if common < len(child.prefix) {
+		split := &node{
+			prefix:   child.prefix[:common],
+			indices:  child.prefix[common : common+1],
+			children: []*node{child},
+		}
+
+		child.prefix = child.prefix[common:]
+		n.children[idx] = split
+		child = split
+	}
+
+	return child.insertStatic(path[common:])
+}
+
+// paramChild gets the parameter node compiled from a Segment, creating it if
+// it doesn't exist.
+func (n *node) paramChild(seg *segment) *node {
+	for _, child := range n.params {
+		if child.key == seg.Path {
+			return child
+		}
+	}
+
+	child := &node{
+		key:        seg.Path,
+		paramName:  seg.ParamName(),
+		constraint: seg.constraint,
+	}
+
+	n.params = append(n.params, child)
+	return child
+}
+
+// wildcardChild gets the wildcard node compiled from a Segment, creating it if
+// it doesn't exist.
+func (n *node) wildcardChild(seg *segment) *node {
+	if n.wildcard == nil {
+		n.wildcard = &node{
+			key:       seg.Path,
+			paramName: seg.ParamName(),
+		}
+	}
+
+	return n.wildcard
+}
+
+// newLeaf compiles the endpoints of a Segment.
+func newLeaf(seg *segment, template routeTemplate, middleware []Middleware) *leaf {
+	handlers := map[string]*variants{}
+	for _, endPt := range seg.Endpoints() {
+		methodHandlers, exists := handlers[endPt.Method()]
+		if !exists {
+			methodHandlers = &variants{}
+			handlers[endPt.Method()] = methodHandlers
+		}
+
+		compiled := &variant{
+			predicates: endPt.Predicates(),
+			handler:    chain(endPt.Handler(), middleware),
+			route:      newRouteInfo(endPt, template.host, template.path, template.params),
+		}
+
+		if len(compiled.predicates) > 0 {
+			methodHandlers.predicated = append(methodHandlers.predicated, compiled)
+			methodHandlers.addVary(compiled.predicates)
+		}
+
+		if endPt.Fallback() {
+			methodHandlers.fallback = compiled
+		}
+	}
+
+	return &leaf{
+		segment:  seg,
+		handlers: handlers,
+		allow:    strings.Join(seg.AllowedMethods(), ", "),
+	}
+}
+
+// lookup finds the leaf of the route that matches a request path. The values
+// of any parameters are appended to params. Like splitPath, a single trailing
+// forward-slash is ignored.
+func (n *node) lookup(path string, params []param) (*leaf, []param) {
+	if len(path) > 1 && path[len(path)-1] == '/' {
+		path = path[:len(path)-1]
+	}
+
+	if len(path) == 0 || path[0] != '/' {
+		path = "/" + path
+	}
+
+	return n.find(path, params)
+}
+
+// find matches the remainder of a path against the node's children. It checks
+// the children in order of priority, as described on Router:
+//
+//  1. The static child that shares the next byte of the path.
+//  2. Constrained parameter children, in the order they were added.
+//  3. Unconstrained parameter children, in the order they were added.
+//  4. The wildcard child.
+//
+// It backtracks when a child matches the start of the path but none of its
+// descendants match the rest.
+func (n *node) find(path string, params []param) (*leaf, []param) {
+	if path == "" {
+		return n.leaf, params
+	}
+
+	if idx := strings.IndexByte(n.indices, path[0]); idx >= 0 {
+		child := n.children[idx]
+		if strings.HasPrefix(path, child.prefix) {
+			if found, all := child.find(path[len(child.prefix):], params); found != nil {
+				return found, all
+			}
+		}
+	}
+
+	if len(n.params) > 0 {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+
+		if end > 0 {
+			// Constraints apply to the decoded value, which only differs when
+			// it has an encoded forward-slash or percent sign.
+			value, decoded := path[:end], path[:end]
+			if strings.IndexByte(value, '%') >= 0 {
+				decoded, _ = url.PathUnescape(value)
+			}
+
+			for _, child := range n.params {
+				if child.constraint != nil && !child.constraint.MatchString(decoded) {
+					continue
+				}
+
+				all := append(params, param{name: child.paramName, value: value})
+				if found, all := child.find(path[end:], all); found != nil {
+					return found, all
+				}
+			}
+		}
+	}
+
+	if n.wildcard != nil && n.wildcard.leaf != nil {
+		return n.wildcard.leaf, append(params, param{name: n.wildcard.paramName, value: path})
+	}
+
+	return nil, params
+}
+
+// commonPrefix gets the length of the longest prefix shared by two strings.
+func commonPrefix(a, b string) int {
+	idx := 0
+	for idx < len(a) && idx < len(b) && a[idx] == b[idx] {
+		idx++
+	}
+
+	return idx
+}
@@ -0,0 +1,211 @@
+package nile
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// benchRoutes is a route table shaped like a typical REST API.
+var benchRoutes = []string{
+	"/",
+	"/health",
+	"/users",
+	"/users/:id",
+	"/users/:id/orders",
+	"/users/:id/orders/:orderID",
+	"/users/:id/orders/:orderID/items",
+	"/products",
+	"/products/new",
+	"/products/:id",
+	"/products/:id/edit",
+	"/products/:id/reviews",
+	"/products/:id/reviews/:reviewID",
+	"/categories",
+	"/categories/:slug",
+	"/categories/:slug/products",
+	"/search",
+	"/files/*path",
+}
+
+func TestTreeMatchesSegments(t *testing.T) {
+	root := newSegment("")
+	for _, route := range benchRoutes {
+		seg, err := newSegmentEndpoint(route, http.MethodGet, namedHandler(route))
+		if err != nil {
+			t.Fatalf("newSegmentEndpoint(%s), want <nil> err, got %v err", route, err)
+		}
+
+		if err := root.AddChild(seg); err != nil {
+			t.Fatalf("Segment.AddChild(%s), want <nil> err, got %v err", route, err)
+		}
+	}
+
+	tree := compileTree(root, "", nil)
+
+	var paths = []string{
+		"/",
+		"/health",
+		"/health/",
+		"/users/42",
+		"/users/42/orders/7/items",
+		"/products/new",
+		"/products/new/edit",
+		"/products/12/reviews/3",
+		"/categories/shoes/products",
+		"/files/a/b/c.txt",
+		"/files",
+		"/unknown",
+		"/users/42/unknown",
+		"/products/12/reviews/3/unknown",
+	}
+
+	for _, path := range paths {
+		wantMatch, wantMatches := root.matchChildren(path)
+		gotLeaf, gotParams := tree.lookup(path, nil)
+
+		if wantMatches != (gotLeaf != nil) {
+			t.Errorf("node.lookup(%s) matches, want %v, got %v", path, wantMatches, gotLeaf != nil)
+			continue
+		}
+
+		if !wantMatches {
+			continue
+		}
+
+		if wantMatch.Segment != gotLeaf.segment {
+			t.Errorf("node.lookup(%s) segment, want %s, got %s", path, wantMatch.Segment.Path, gotLeaf.segment.Path)
+		}
+
+		c := &context{params: gotParams}
+		for _, want := range wantMatch.Context.params {
+			if got, found := c.TryParam(want.name); !found || got != want.value {
+				t.Errorf("node.lookup(%s) param %s, want (%s, true), got (%s, %v)", path, want.name, want.value, got, found)
+			}
+		}
+
+		if len(gotParams) != len(wantMatch.Context.params) {
+			t.Errorf("node.lookup(%s) params, want %d, got %d", path, len(wantMatch.Context.params), len(gotParams))
+		}
+	}
+}
+
+func TestTreeLookupAllocs(t *testing.T) {
+	routes := newBenchRouter(t).compiledTable().routes
+
+	for _, path := range []string{"/products/new", "/users/42/orders/7/items"} {
+		allocs := testing.AllocsPerRun(100, func() {
+			c := acquireContext()
+			route, params := routes.lookup(path, c.params)
+			c.params = params
+			if route == nil {
+				t.Fatalf("node.lookup(%s), want match, got <nil>", path)
+			}
+			releaseContext(c)
+		})
+
+		if allocs != 0 {
+			t.Errorf("node.lookup(%s) allocations, want 0, got %v", path, allocs)
+		}
+	}
+}
+
+// discardWriter is an http.ResponseWriter that discards the response.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w discardWriter) Header() http.Header         { return w.header }
+func (w discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w discardWriter) WriteHeader(int)             {}
+
+func TestRouterServeHTTPAllocs(t *testing.T) {
+	// The handler writes nothing, so that only the allocations of routing are
+	// counted. Writing a JSON Response allocates its body and the values of
+	// its Content-Type and Content-Length headers on top of these.
+	var noop Response = handlerResponse{handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})}
+	r := New().(*router)
+	for _, route := range benchRoutes {
+		r.GET(route, func(Context) Response { return noop })
+	}
+
+	w := discardWriter{header: http.Header{}}
+	for _, path := range []string{"/products/new", "/users/42/orders/7/items"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		allocs := testing.AllocsPerRun(100, func() {
+			r.ServeHTTP(w, req)
+		})
+
+		if allocs != 0 {
+			t.Errorf("Router.ServeHTTP(GET %s) allocations, want 0, got %v", path, allocs)
+		}
+	}
+}
+
+func newBenchRouter(tb testing.TB) *router {
+	r := New().(*router)
+	for _, route := range benchRoutes {
+		if err := r.GET(route, namedHandler(route)); err != nil {
+			tb.Fatalf("Router.GET(%s) error, want <nil>, got %v", route, err)
+		}
+	}
+
+	return r
+}
+
+func benchmarkSegmentMatches(b *testing.B, path string) {
+	tree := newBenchRouter(b).currentTable().tree
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, matches := tree.matchChildren(path); !matches {
+			b.Fatalf("Segment.matchChildren(%s), want match", path)
+		}
+	}
+}
+
+func benchmarkTreeLookup(b *testing.B, path string) {
+	routes := newBenchRouter(b).compiledTable().routes
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := acquireContext()
+		route, params := routes.lookup(path, c.params)
+		if route == nil {
+			b.Fatalf("node.lookup(%s), want match", path)
+		}
+		c.params = params
+		releaseContext(c)
+	}
+}
+
+func BenchmarkSegmentMatchesStatic(b *testing.B) {
+	benchmarkSegmentMatches(b, "/products/new")
+}
+
+func BenchmarkTreeLookupStatic(b *testing.B) {
+	benchmarkTreeLookup(b, "/products/new")
+}
+
+func BenchmarkSegmentMatchesParams(b *testing.B) {
+	benchmarkSegmentMatches(b, "/users/42/orders/7/items")
+}
+
+func BenchmarkTreeLookupParams(b *testing.B) {
+	benchmarkTreeLookup(b, "/users/42/orders/7/items")
+}
+
+func BenchmarkRouterServeHTTP(b *testing.B) {
+	r := newBenchRouter(b)
+	req := httptest.NewRequest(http.MethodGet, "/users/42/orders/7/items", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Body.Reset()
+		r.ServeHTTP(w, req)
+	}
+}
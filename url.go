@@ -0,0 +1,115 @@
+package nile
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// routeURL is the path template of a named route, which URLs for the route
+// are generated from.
+type routeURL struct {
+	path     string
+	segments []*segment
+	// host is the host pattern of the route, if it only matches one.
+	host *hostPattern
+}
+
+// newRouteURL creates the template of a route from its path and the Segment
+// that was created for it. It must be called before the Segment is added to a
+// tree, while it still has at most one child at each level.
+func newRouteURL(path string, seg *segment) *routeURL {
+	segments := []*segment{}
+	for seg != nil {
+		segments = append(segments, seg)
+
+		children := seg.Children()
+		seg = nil
+		if len(children) > 0 {
+			seg = children[0]
+		}
+	}
+
+	return &routeURL{
+		path:     path,
+		segments: segments,
+	}
+}
+
+// build generates a URL from the template. Every parameter in the template
+// must have a value that satisfies its constraint. The URL of a route that
+// only matches a host pattern starts with the host, without a scheme.
+func (u *routeURL) build(params map[string]string, query url.Values) (string, error) {
+	var b strings.Builder
+	if u.host != nil {
+		host, err := u.buildHost(params)
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString("//")
+		b.WriteString(host)
+	}
+
+	for _, seg := range u.segments {
+		b.WriteString("/")
+
+		if !isParam(seg.Path) && !isWildcard(seg.Path) {
+			b.WriteString(seg.Path)
+			continue
+		}
+
+		name := seg.ParamName()
+		value, found := params[name]
+		if !found || value == "" {
+			return "", fmt.Errorf("Missing parameter %s for route %s", name, u.path)
+		}
+
+		if seg.constraint != nil && !seg.constraint.MatchString(value) {
+			return "", fmt.Errorf("Parameter %s with value %s does not satisfy the constraint of route %s", name, value, u.path)
+		}
+
+		if isWildcard(seg.Path) {
+			parts := strings.Split(value, "/")
+			for idx, part := range parts {
+				parts[idx] = url.PathEscape(part)
+			}
+			b.WriteString(strings.Join(parts, "/"))
+		} else {
+			b.WriteString(url.PathEscape(value))
+		}
+	}
+
+	if len(query) > 0 {
+		b.WriteString("?")
+		b.WriteString(query.Encode())
+	}
+
+	return b.String(), nil
+}
+
+// buildHost generates the host of a URL from the host pattern of the template.
+// Every parameter in the pattern must have a value that is a single label and
+// satisfies its constraint.
+func (u *routeURL) buildHost(params map[string]string) (string, error) {
+	labels := make([]string, len(u.host.labels))
+	for idx, label := range u.host.labels {
+		if label.paramName == "" {
+			labels[idx] = label.value
+			continue
+		}
+
+		value, found := params[label.paramName]
+		if !found || value == "" {
+			return "", fmt.Errorf("Missing parameter %s for host %s of route %s", label.paramName, u.host.pattern, u.path)
+		}
+
+		if strings.ContainsAny(value, "./:") || (label.constraint != nil && !label.constraint.MatchString(value)) {
+			return "", fmt.Errorf("Parameter %s with value %s does not satisfy host %s of route %s", label.paramName, value, u.host.pattern, u.path)
+		}
+
+		labels[idx] = value
+	}
+
+	return strings.Join(labels, "."), nil
+}
@@ -0,0 +1,323 @@
+// Package validate checks the fields of a struct against the rules in their
+// validate tags, such as:
+//
+//	type Product struct {
+//		Name  string   `json:"name" validate:"required,max=64"`
+//		Email string   `json:"email" validate:"email"`
+//		State string   `json:"state" validate:"oneof=draft published"`
+//		Tags  []string `json:"tags" validate:"omitempty,max=10"`
+//	}
+//
+// The rules are:
+//
+//   - required: the field must not be its zero value.
+//   - min=n and max=n: numbers must be at least or at most n, and strings,
+//     slices and maps must have at least or at most n characters or items.
+//   - email: a string must be an email address.
+//   - oneof=a b c: the field must be one of the space-separated values.
+//   - omitempty: the other rules, apart from required, are skipped when the
+//     field is its zero value, such as a nil pointer, so that it can be left
+//     out.
+//
+// Every rule is checked, including for fields that are their zero value,
+// unless the field has the omitempty rule. A nil pointer is checked as the
+// zero value of the type that it points to. Nested structs, pointers to
+// structs and slices of structs are validated as well.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes a field that broke a rule.
+type FieldError struct {
+	// Field is the path of the field, such as items[0].name. It is built from
+	// the names in json tags, or the names of the fields if they don't have
+	// one.
+	Field string `json:"field,omitempty"`
+	// Code is the rule that was broken, such as required.
+	Code string `json:"code"`
+	// Message describes the broken rule.
+	Message string `json:"message"`
+	// Value is the value that was rejected.
+	Value interface{} `json:"value,omitempty"`
+	// Offset is the number of bytes of a request body that were read when the
+	// error was found, for errors found while decoding the body.
+	Offset int64 `json:"offset,omitempty"`
+}
+
+// Error returns the message of the FieldError.
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// Errors is the list of FieldErrors of a struct.
+type Errors []FieldError
+
+// Error joins the messages of the FieldErrors.
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for idx, fieldErr := range e {
+		messages[idx] = fieldErr.Message
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Struct validates a struct, or a pointer to one, against the validate tags of
+// its fields. It returns Errors listing every field that broke a rule, or
+// another error if a tag is invalid.
+func Struct(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("Unable to validate %T: it isn't a struct", v)
+	}
+
+	var errs Errors
+	if err := validateStruct(value, "", &errs); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validateStruct validates the fields of a struct. The path of the struct is
+// prepended to the paths of its fields.
+func validateStruct(value reflect.Value, path string, errs *Errors) error {
+	structType := value.Type()
+	for idx := 0; idx < structType.NumField(); idx++ {
+		fieldType := structType.Field(idx)
+		if fieldType.PkgPath != "" && !fieldType.Anonymous {
+			continue
+		}
+
+		field := value.Field(idx)
+		fieldPath := path
+		if !fieldType.Anonymous {
+			fieldPath = joinPath(path, fieldName(fieldType))
+		}
+
+		tag := fieldType.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+
+		if tag != "" {
+			if err := validateField(field, fieldPath, tag, errs); err != nil {
+				return err
+			}
+		}
+
+		if err := validateNested(field, fieldPath, errs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateNested validates a field that holds structs, if it has any.
+func validateNested(field reflect.Value, path string, errs *Errors) error {
+	for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return nil
+		}
+
+		field = field.Elem()
+	}
+
+	switch field.Kind() {
+	case reflect.Struct:
+		return validateStruct(field, path, errs)
+	case reflect.Slice, reflect.Array:
+		for idx := 0; idx < field.Len(); idx++ {
+			if err := validateNested(field.Index(idx), fmt.Sprintf("%s[%d]", path, idx), errs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateField checks a field against the rules of its tag. Only the first
+// broken rule is reported.
+func validateField(field reflect.Value, path string, tag string, errs *Errors) error {
+	// A pointer is only empty when it's nil, so that a pointer to a zero value
+	// is checked even with omitempty.
+	empty := field.IsZero()
+	for field.Kind() == reflect.Ptr && !field.IsNil() {
+		field = field.Elem()
+	}
+
+	// A nil pointer is checked as the zero value of the type it points to,
+	// but is still reported without a value.
+	value := field
+	for value.Kind() == reflect.Ptr {
+		value = reflect.Zero(value.Type().Elem())
+	}
+
+	tagRules := strings.Split(tag, ",")
+	omitEmpty := false
+	for _, rule := range tagRules {
+		omitEmpty = omitEmpty || rule == "omitempty"
+	}
+
+	for _, rule := range tagRules {
+		name, param := rule, ""
+		if eq := strings.IndexByte(rule, '='); eq >= 0 {
+			name, param = rule[:eq], rule[eq+1:]
+		}
+
+		if name == "omitempty" {
+			continue
+		}
+
+		check, found := rules[name]
+		if !found {
+			return fmt.Errorf("Unable to validate %s: unknown rule %s", path, name)
+		}
+
+		if omitEmpty && name != "required" && empty {
+			continue
+		}
+
+		message, err := check(value, param)
+		if err != nil {
+			return fmt.Errorf("Unable to validate %s: %v", path, err)
+		}
+
+		if message != "" {
+			*errs = append(*errs, FieldError{
+				Field:   path,
+				Code:    name,
+				Message: fmt.Sprintf("%s %s", path, message),
+				Value:   rejectedValue(field),
+			})
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// rule checks a field against a rule with the parameter given in the tag. It
+// returns a message describing how the rule was broken, or an empty message if
+// it wasn't. An error is returned if the rule can't be applied to the field.
+type rule func(field reflect.Value, param string) (string, error)
+
+var rules = map[string]rule{
+	"required": required,
+	"min":      bound("at least", func(actual, limit float64) bool { return actual >= limit }),
+	"max":      bound("at most", func(actual, limit float64) bool { return actual <= limit }),
+	"email":    email,
+	"oneof":    oneOf,
+}
+
+func required(field reflect.Value, param string) (string, error) {
+	if !field.IsValid() || field.IsZero() || (field.Kind() == reflect.Ptr && field.IsNil()) {
+		return "is required", nil
+	}
+
+	return "", nil
+}
+
+// bound creates the min and max rules, which compare the value of a number, or
+// the length of a string, slice or map, with a limit.
+func bound(description string, within func(actual, limit float64) bool) rule {
+	return func(field reflect.Value, param string) (string, error) {
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid limit %s", param)
+		}
+
+		var actual float64
+		format := "must be %s %s"
+		switch field.Kind() {
+		case reflect.String:
+			actual, format = float64(utf8.RuneCountInString(field.String())), "must be %s %s characters long"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			actual, format = float64(field.Len()), "must have %s %s items"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			actual = float64(field.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			actual = float64(field.Uint())
+		case reflect.Float32, reflect.Float64:
+			actual = field.Float()
+		default:
+			return "", fmt.Errorf("a limit can't be applied to a %s", field.Type())
+		}
+
+		if within(actual, limit) {
+			return "", nil
+		}
+
+		return fmt.Sprintf(format, description, param), nil
+	}
+}
+
+func email(field reflect.Value, param string) (string, error) {
+	if field.Kind() != reflect.String {
+		return "", fmt.Errorf("email can't be applied to a %s", field.Type())
+	}
+
+	address, err := mail.ParseAddress(field.String())
+	if err != nil || address.Address != field.String() {
+		return "must be an email address", nil
+	}
+
+	return "", nil
+}
+
+func oneOf(field reflect.Value, param string) (string, error) {
+	options := strings.Fields(param)
+	actual := fmt.Sprint(field.Interface())
+	for _, option := range options {
+		if actual == option {
+			return "", nil
+		}
+	}
+
+	return fmt.Sprintf("must be one of %s", strings.Join(options, ", ")), nil
+}
+
+// rejectedValue gets the value of a field to report in a FieldError.
+func rejectedValue(field reflect.Value) interface{} {
+	if !field.IsValid() || (field.Kind() == reflect.Ptr && field.IsNil()) || !field.CanInterface() {
+		return nil
+	}
+
+	return field.Interface()
+}
+
+// fieldName gets the name of a field in its json tag, or its name in Go if it
+// doesn't have one.
+func fieldName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+
+	return field.Name
+}
+
+// joinPath appends the name of a field to the path of its struct.
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
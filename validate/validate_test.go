@@ -0,0 +1,134 @@
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	City    string `json:"city" validate:"required"`
+	Country string `json:"country" validate:"oneof=CA US"`
+}
+
+type item struct {
+	SKU      string `json:"sku" validate:"required,max=8"`
+	Quantity int    `json:"quantity" validate:"min=1,max=99"`
+	Discount *int   `json:"discount" validate:"max=50"`
+}
+
+type order struct {
+	Email    string   `json:"email" validate:"required,email"`
+	Note     string   `validate:"max=5"`
+	Rating   *float64 `json:"rating" validate:"omitempty,min=0.5,max=5"`
+	Coupon   *string  `json:"coupon" validate:"required"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Shipping address  `json:"shipping"`
+	Billing  *address `json:"billing"`
+	Items    []item   `json:"items" validate:"required"`
+	Ignored  string   `json:"ignored" validate:"-"`
+	internal string   `validate:"required"`
+}
+
+func TestStruct(t *testing.T) {
+	coupon := "SAVE"
+	rating := 6.0
+
+	valid := order{
+		Email:    "ada@example.com",
+		Coupon:   &coupon,
+		Shipping: address{City: "Toronto", Country: "CA"},
+		Items:    []item{{SKU: "LAMP", Quantity: 1}},
+	}
+
+	var tests = []struct {
+		name   string
+		modify func(o *order)
+		want   Errors
+	}{
+		{"valid", func(o *order) {}, nil},
+		{"missing", func(o *order) { o.Email, o.Coupon, o.Items = "", nil, nil }, Errors{
+			{Field: "email", Code: "required", Message: "email is required", Value: ""},
+			{Field: "coupon", Code: "required", Message: "coupon is required"},
+			{Field: "items", Code: "required", Message: "items is required", Value: []item(nil)},
+		}},
+		{"invalid", func(o *order) {
+			o.Email = "Ada <ada@example.com>"
+			o.Note = "Leave at the door"
+			o.Rating = &rating
+			o.Tags = []string{"a", "b", "c"}
+		}, Errors{
+			{Field: "email", Code: "email", Message: "email must be an email address", Value: "Ada <ada@example.com>"},
+			{Field: "Note", Code: "max", Message: "Note must be at most 5 characters long", Value: "Leave at the door"},
+			{Field: "rating", Code: "max", Message: "rating must be at most 5", Value: 6.0},
+			{Field: "tags", Code: "max", Message: "tags must have at most 2 items", Value: []string{"a", "b", "c"}},
+		}},
+		{"nested", func(o *order) {
+			o.Shipping.Country = "MX"
+			o.Billing = &address{}
+			o.Items = append(o.Items, item{SKU: "CHAIR-SET-4", Quantity: 100})
+		}, Errors{
+			{Field: "shipping.country", Code: "oneof", Message: "shipping.country must be one of CA, US", Value: "MX"},
+			{Field: "billing.city", Code: "required", Message: "billing.city is required", Value: ""},
+			{Field: "billing.country", Code: "oneof", Message: "billing.country must be one of CA, US", Value: ""},
+			{Field: "items[1].sku", Code: "max", Message: "items[1].sku must be at most 8 characters long", Value: "CHAIR-SET-4"},
+			{Field: "items[1].quantity", Code: "max", Message: "items[1].quantity must be at most 99", Value: 100},
+		}},
+		{"zero values", func(o *order) {
+			zero := 0.0
+			o.Rating = &zero
+			o.Items[0].Quantity = 0
+		}, Errors{
+			{Field: "rating", Code: "min", Message: "rating must be at least 0.5", Value: 0.0},
+			{Field: "items[0].quantity", Code: "min", Message: "items[0].quantity must be at least 1", Value: 0},
+		}},
+	}
+
+	for _, test := range tests {
+		o := valid
+		o.Items = append([]item{}, valid.Items...)
+		test.modify(&o)
+
+		err := Struct(&o)
+		if test.want == nil {
+			if err != nil {
+				t.Errorf("%s: Struct() error, want <nil>, got %v", test.name, err)
+			}
+			continue
+		}
+
+		got, ok := err.(Errors)
+		if !ok {
+			t.Errorf("%s: Struct() error, want Errors, got %T", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Struct() error, want %+v, got %+v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestStructInvalidTags(t *testing.T) {
+	var tests = []struct {
+		name  string
+		value interface{}
+	}{
+		{"unknown rule", &struct {
+			Name string `validate:"requried"`
+		}{"Ada"}},
+		{"invalid limit", &struct {
+			Name string `validate:"max=ten"`
+		}{"Ada"}},
+		{"limit on a bool", &struct {
+			Active bool `validate:"min=1"`
+		}{true}},
+		{"not a struct", "Ada"},
+	}
+
+	for _, test := range tests {
+		err := Struct(test.value)
+		if _, isErrors := err.(Errors); err == nil || isErrors {
+			t.Errorf("%s: Struct() error, want an invalid tag error, got %v", test.name, err)
+		}
+	}
+}